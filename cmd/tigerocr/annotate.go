@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"image/color"
 	"io/ioutil"
 	"path/filepath"
 	"strings"
@@ -10,24 +9,8 @@ import (
 	"github.com/ughe/tigerocr/ocr"
 )
 
-var (
-	blue   = color.RGBA{0, 0, 255, 255}
-	red    = color.RGBA{255, 0, 0, 255}
-	orange = color.RGBA{255, 165, 0, 255}
-)
-
 func annotate(img []byte, detection *ocr.Detection, b, l, w bool, dstFilename string) error {
-	lid := strings.ToLower(detection.AlgoID)
-	var col color.Color
-	if strings.Contains(lid, "aws") {
-		col = orange
-	} else if strings.Contains(lid, "azu") {
-		col = blue
-	} else if strings.Contains(lid, "gcp") {
-		col = red
-	} else {
-		col = color.Black
-	}
+	col := ocr.AlgoIDColor(detection.AlgoID)
 
 	dstImg, err := detection.Annotate(img, col, b, l, w)
 	if err != nil {
@@ -53,6 +36,9 @@ func annotateCommand(b, l, w bool, imageFilename, coordFilename string) error {
 	}
 
 	detection, err := convertToBLW(buf, raw, coordFilename)
+	if err != nil {
+		return err
+	}
 
 	blw := ""
 	if b {
@@ -66,7 +52,7 @@ func annotateCommand(b, l, w bool, imageFilename, coordFilename string) error {
 	}
 	dstFilename := fmt.Sprintf("%v.%v.%v.jpg", strings.TrimSuffix(filepath.Base(imageFilename), filepath.Ext(imageFilename)), blw, strings.ToLower(detection.AlgoID))
 
-	if len(detection.Blocks) == 0 {
+	if nb, _, _ := detection.CountBLW(); nb == 0 {
 		return fmt.Errorf("Failed to annotate. No blocks, lines, or words in: %s\n", coordFilename)
 	}
 
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ughe/tigerocr/editdist"
+	"github.com/ughe/tigerocr/normalize"
+)
+
+// Scores of one provider on one pointer
+type benchScore struct {
+	cer, wer, secs float64
+}
+
+// Returns the mean, median and 95th percentile (nearest rank) of xs
+func summarize(xs []float64) (float64, float64, float64) {
+	if len(xs) == 0 {
+		return 0, 0, 0
+	}
+	sorted := append([]float64(nil), xs...)
+	sort.Float64s(sorted)
+	sum := 0.0
+	for _, x := range sorted {
+		sum += x
+	}
+	median := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + median) / 2
+	}
+	p95 := sorted[int(math.Ceil(0.95*float64(len(sorted))))-1]
+	return sum / float64(len(sorted)), median, p95
+}
+
+// Splits <ptr>.<provider>.{blw,json} into the pointer and provider
+func benchName(filename string) (string, string, bool) {
+	base := filepath.Base(filename)
+	ext := filepath.Ext(base)
+	if (ext != ".blw" && ext != ".json") || strings.HasSuffix(base, ".fixture.json") {
+		return "", "", false
+	}
+	// Pointers may contain dots but providers do not
+	name := strings.TrimSuffix(base, ext)
+	i := strings.LastIndex(name, ".")
+	if i <= 0 || i == len(name)-1 {
+		return "", "", false
+	}
+	return name[:i], name[i+1:], true
+}
+
+// Scores the ocr results (<ptr>.<provider>.blw or .json) against the
+// ground truth (<ptr>.txt, or ALTO or PAGE <ptr>.xml). Writes results.csv
+// for the explorer and prints summary statistics
+func benchCommand(truthDir, ocrDir, dst string, algo editdist.Algorithm, norm normalize.Normalizer) error {
+	listing, err := ioutil.ReadDir(ocrDir)
+	if err != nil {
+		return err
+	}
+	scores := make(map[string]map[string]benchScore) // provider to ptr
+	for _, l := range listing {
+		ptr, provider, ok := benchName(l.Name())
+		if l.IsDir() || !ok {
+			continue
+		}
+		truth, err := readTruth(truthDir, ptr, norm)
+		if os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "[WARNING] No truth for %s\n", l.Name())
+			continue
+		} else if err != nil {
+			return err
+		}
+		raw, err := ioutil.ReadFile(path.Join(ocrDir, l.Name()))
+		if err != nil {
+			return err
+		}
+		detection, err := convertToBLW(nil, raw, l.Name())
+		if err != nil {
+			return fmt.Errorf("%s: %v", l.Name(), err)
+		}
+		text := norm.String(detection.Plaintext())
+		dist, tlen := algo.Distance([]byte(text), truth)
+		if scores[provider] == nil {
+			scores[provider] = make(map[string]benchScore)
+		}
+		scores[provider][ptr] = benchScore{
+			cer:  editdist.CER(dist, tlen),
+			wer:  editdist.WER(text, string(truth), editdist.Fields),
+			secs: float64(detection.Millis) / 1000,
+		}
+	}
+	if len(scores) == 0 {
+		return fmt.Errorf("No ocr results with ground truth in %s", ocrDir)
+	}
+
+	// Only pointers every provider has are compared
+	providers := make([]string, 0, len(scores))
+	var ptrs []string
+	for p, s := range scores {
+		providers = append(providers, p)
+		names := make([]string, 0, len(s))
+		for ptr := range s {
+			names = append(names, ptr)
+		}
+		if ptrs == nil {
+			ptrs = names
+		} else {
+			ptrs = comm(ptrs, names)
+		}
+	}
+	sort.Strings(providers)
+	sort.Strings(ptrs)
+	for _, p := range providers {
+		if n := len(scores[p]) - len(ptrs); n > 0 {
+			fmt.Fprintf(os.Stderr, "[WARNING] Skipping %d %s results missing from other providers\n", n, p)
+		}
+	}
+
+	// Results in the explorer's format: one row per metric
+	rows := []string{"ptr," + strings.Join(ptrs, ",")}
+	metric := func(name string, value func(benchScore) float64, prec int) {
+		for _, p := range providers {
+			fields := make([]string, 0, len(ptrs)+1)
+			fields = append(fields, strings.ToUpper(p)+" "+name)
+			for _, ptr := range ptrs {
+				fields = append(fields, strconv.FormatFloat(value(scores[p][ptr]), 'f', prec, 64))
+			}
+			rows = append(rows, strings.Join(fields, ","))
+		}
+	}
+	metric("CER", func(s benchScore) float64 { return s.cer }, 5)
+	metric("WER", func(s benchScore) float64 { return s.wer }, 5)
+	metric("Seconds", func(s benchScore) float64 { return s.secs }, 3)
+	if err := ioutil.WriteFile(dst, []byte(strings.Join(rows, "\n")), 0644); err != nil {
+		return err
+	}
+
+	// Wins go to every provider with the lowest CER of a pointer
+	wins := make(map[string]int)
+	for _, ptr := range ptrs {
+		lowest := math.Inf(1)
+		for _, p := range providers {
+			lowest = math.Min(lowest, scores[p][ptr].cer)
+		}
+		for _, p := range providers {
+			if scores[p][ptr].cer == lowest {
+				wins[p]++
+			}
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "provider\tmetric\tmean\tmedian\tp95\twins")
+	for _, p := range providers {
+		for i, m := range []string{"CER", "WER", "Seconds"} {
+			xs := make([]float64, 0, len(ptrs))
+			for _, ptr := range ptrs {
+				s := scores[p][ptr]
+				xs = append(xs, []float64{s.cer, s.wer, s.secs}[i])
+			}
+			mean, median, p95 := summarize(xs)
+			win := ""
+			if i == 0 {
+				win = strconv.Itoa(wins[p])
+			}
+			fmt.Fprintf(w, "%s\t%s\t%.4f\t%.4f\t%.4f\t%s\n", p, m, mean, median, p95, win)
+		}
+	}
+	w.Flush()
+	fmt.Printf("[INFO] Compared %d pages of %d providers: %s\n", len(ptrs), len(providers), dst)
+	return nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestBenchName(t *testing.T) {
+	for _, c := range []struct {
+		filename, ptr, provider string
+		ok                      bool
+	}{
+		{"book-001.aws.blw", "book-001", "aws", true},
+		{"dir/vol.2-p001.aws.blw", "vol.2-p001", "aws", true},
+		{"vol.2-p001.gcp.json", "vol.2-p001", "gcp", true},
+		{"book-001.blw", "", "", false},
+		{".aws.blw", "", "", false},
+		{"book-001.aws.fixture.json", "", "", false},
+		{"book-001.aws.txt", "", "", false},
+	} {
+		ptr, provider, ok := benchName(c.filename)
+		if ptr != c.ptr || provider != c.provider || ok != c.ok {
+			t.Fatalf("%s: Expected %q %q %v. Received: %q %q %v", c.filename, c.ptr, c.provider, c.ok, ptr, provider, ok)
+		}
+	}
+}
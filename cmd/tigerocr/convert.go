@@ -14,7 +14,27 @@ import (
 	"github.com/ughe/tigerocr/ocr"
 )
 
-func convert(imgFilename, jsnFilename, dstPath string) (string, error) {
+// Encodes the detection as blw, hocr or alto. Returns the file extension
+func encodeDetection(detection *ocr.Detection, to string) ([]byte, string, error) {
+	switch to {
+	case "blw":
+		encoded, err := json.Marshal(detection)
+		return encoded, ".blw", err
+	case "hocr":
+		encoded, err := detection.HOCR()
+		return encoded, ".hocr", err
+	case "alto":
+		// Not .xml, which PAGE-XML and ALTO inputs already use
+		encoded, err := detection.ALTO()
+		return encoded, ".alto.xml", err
+	default:
+		return nil, "", fmt.Errorf("Format %s is not {blw, hocr, alto}", to)
+	}
+}
+
+// Converts the json (or blw) result to the format to. Writes the result to
+// dstPath with the extension of the format
+func convert(imgFilename, jsnFilename, dstPath, to string) (string, error) {
 	img, err := ioutil.ReadFile(imgFilename)
 	if err != nil {
 		return "", err
@@ -29,13 +49,20 @@ func convert(imgFilename, jsnFilename, dstPath string) (string, error) {
 		return "", err
 	}
 
-	encoded, err := json.Marshal(detection)
+	encoded, ext, err := encodeDetection(detection, to)
 	if err != nil {
 		return "", err
 	}
 
-	// Output to dstPath with jsnFilename 's/json/blw/'
-	dstFilename := path.Join(dstPath, strings.TrimSuffix(filepath.Base(jsnFilename), filepath.Ext(jsnFilename))+".blw")
+	// Output to dstPath with jsnFilename 's/json/<ext>/'
+	dstFilename := path.Join(dstPath, strings.TrimSuffix(filepath.Base(jsnFilename), filepath.Ext(jsnFilename))+ext)
+
+	// Such as -to=blw of a blw file in the working directory
+	for _, src := range []string{imgFilename, jsnFilename} {
+		if sameFile(src, dstFilename) {
+			return "", fmt.Errorf("Refusing to overwrite the input %s", src)
+		}
+	}
 
 	if err := ioutil.WriteFile(dstFilename, encoded, 0600); err != nil {
 		return "", err
@@ -43,12 +70,22 @@ func convert(imgFilename, jsnFilename, dstPath string) (string, error) {
 	return dstFilename, nil
 }
 
-func convertCommand(imgFilename, jsnFilename string) error {
-	dstFilename, err := convert(imgFilename, jsnFilename, "")
+// Returns true if both paths exist and are the same file
+func sameFile(a, b string) bool {
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	return err == nil && os.SameFile(fa, fb)
+}
+
+func convertCommand(imgFilename, jsnFilename, to string) error {
+	dstFilename, err := convert(imgFilename, jsnFilename, "", to)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("[INFO] Converted json to blw: %v\n", dstFilename)
+	fmt.Printf("[INFO] Converted %s to %s: %v\n", strings.TrimPrefix(filepath.Ext(jsnFilename), "."), to, dstFilename)
 	return nil
 }
 
@@ -130,6 +167,10 @@ func convertCommandPDF(imgDir, blwDir, providerPrefix string) error {
 		if err != nil {
 			return err
 		}
+		// Each image is one page
+		if len(detection.Pages) > 1 {
+			return fmt.Errorf("%s: Expected a single page instead of: %d pages", blwName, len(detection.Pages))
+		}
 		// Create a PDF page
 		pdf.AddPageFormat("P", gofpdf.SizeType{Wd: w, Ht: h})
 		opt := gofpdf.ImageOptions{
@@ -142,27 +183,29 @@ func convertCommandPDF(imgDir, blwDir, providerPrefix string) error {
 		// Add the text in behind the image (instead of in invisible layer)
 		pdf.BeginLayer(hiddenLayer)
 		fmt.Println("[INFO] Drawing text on a page")
-		for _, b := range detection.Blocks {
-			for _, l := range b.Lines {
-				for _, w := range l.Words {
-					bnds, err := ocr.DecodeBounds(w.Bounds)
-					if err != nil {
-						return err
-					}
-					ww, wh := float64(bnds.W), float64(bnds.H) // Word W,H
-					size := fitFontSize(w.Text, ww)
-					if size != 0 && size != fontSize {
-						fontSize = size // Update last font size to new
-						pdf.SetFontSize(fontSize)
-					}
-					// If font is smaller than height, shift up by diff
-					_, h := pdf.GetFontSize()
-					diff := 0.0
-					if wh > h {
-						diff = (wh - h)
+		for _, p := range detection.Pages {
+			for _, b := range p.Blocks {
+				for _, l := range b.Lines {
+					for _, w := range l.Words {
+						bnds, err := ocr.DecodeBounds(w.Bounds)
+						if err != nil {
+							return err
+						}
+						ww, wh := float64(bnds.W), float64(bnds.H) // Word W,H
+						size := fitFontSize(w.Text, ww)
+						if size != 0 && size != fontSize {
+							fontSize = size // Update last font size to new
+							pdf.SetFontSize(fontSize)
+						}
+						// If font is smaller than height, shift up by diff
+						_, h := pdf.GetFontSize()
+						diff := 0.0
+						if wh > h {
+							diff = (wh - h)
+						}
+						// Set font if the size has changed
+						pdf.Text(float64(bnds.X), float64(bnds.Y)+wh-diff, w.Text+" ")
 					}
-					// Set font if the size has changed
-					pdf.Text(float64(bnds.X), float64(bnds.Y)+wh-diff, w.Text+" ")
 				}
 			}
 		}
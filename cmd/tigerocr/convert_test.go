@@ -0,0 +1,37 @@
+package main
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+)
+
+func TestConvertKeepsInput(t *testing.T) {
+	dir := t.TempDir()
+	img := path.Join(dir, "page.png")
+	if err := ioutil.WriteFile(img, []byte("unread for blw"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	blw := path.Join(dir, "page.blw")
+	raw := []byte(`{"algo":"aws","pages":[{"width":10,"height":10,"blocks":[{"xywh":"0,0,5,5","lines":[{"xywh":"0,0,5,5","words":[{"xywh":"0,0,5,5","text":"hi"}]}]}]}]}`)
+	if err := ioutil.WriteFile(blw, raw, 0600); err != nil {
+		t.Fatal(err)
+	}
+	alto, err := convert(img, blw, dir, "alto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alto != path.Join(dir, "page.alto.xml") {
+		t.Fatalf("Expected page.alto.xml. Received: %s", alto)
+	}
+	// ALTO of ALTO gets its own name, but blw of blw would replace its input
+	if again, err := convert(img, alto, dir, "alto"); err != nil || again == alto {
+		t.Fatalf("Expected a new name for alto of alto. Received: %s %v", again, err)
+	}
+	if _, err := convert(img, blw, dir, "blw"); err == nil {
+		t.Fatal("Expected blw of blw to the same directory to fail")
+	}
+	if after, _ := ioutil.ReadFile(blw); string(after) != string(raw) {
+		t.Fatal("Expected the blw input unchanged")
+	}
+}
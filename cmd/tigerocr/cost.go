@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ughe/tigerocr/ocr"
+)
+
+// Parses the pages already billed this month by each provider, i.e.
+// aws=1200,gcp=800. Costs follow the tiers after them
+func parseBilled(s string) (map[string]int, error) {
+	billed := make(map[string]int)
+	if s == "" {
+		return billed, nil
+	}
+	for _, kv := range strings.Split(s, ",") {
+		fields := strings.SplitN(kv, "=", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("Expected provider=pages. Found: %s", kv)
+		}
+		key, ok := providerKey(fields[0])
+		if !ok {
+			return nil, fmt.Errorf("Provider %s is not registered", fields[0])
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("Expected a count of pages for %s. Found: %s", fields[0], fields[1])
+		}
+		billed[key] += n
+	}
+	return billed, nil
+}
+
+// Prints the estimate of each provider for the number of pages it will run
+// and returns the total in dollars. Estimates follow the tiers after the
+// pages billed earlier in the billing period, by provider key (may be nil)
+func printEstimates(w io.Writer, pages, billed map[string]int) float64 {
+	keys := make([]string, 0, len(pages))
+	for k := range pages {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	total := 0.0
+	for _, k := range keys {
+		p, _ := ocr.ProviderByKey(k)
+		cost := p.Pricing.After(billed[k], pages[k])
+		total += cost
+		fmt.Fprintf(w, "[INFO] Estimate %s: $%.4f (%d pages)\n", p.Name, cost, pages[k])
+	}
+	return total
+}
+
+var ledgerFields = []string{"date", "provider", "image", "attempts", "status", "cost"}
+
+// Ledger appends a row for every OCR call, so that calls can be reconciled
+// with the providers' bills. Costs follow each provider's tiers, counting the
+// successful calls already in the ledger this month (UTC) and the pages
+// billed elsewhere (-billed)
+type ledger struct {
+	mu     sync.Mutex
+	f      *os.File
+	w      *csv.Writer
+	billed map[string]int // By provider key
+}
+
+// Returns the successful calls of each provider in the ledger this month
+// (UTC) plus the prior pages of each. A missing ledger has none
+func readBilled(filename string, prior map[string]int) (map[string]int, error) {
+	month := time.Now().UTC().Format("2006-01")
+	billed := make(map[string]int)
+	for k, n := range prior {
+		billed[k] = n
+	}
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return billed, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = len(ledgerFields)
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		if row[4] == "ok" && len(row[0]) >= len(month) && row[0][:len(month)] == month {
+			billed[row[1]]++
+		}
+	}
+	return billed, nil
+}
+
+// Opens the ledger for appending, creating it if needed. Prior is the pages
+// of each provider billed this month outside of the ledger
+func openLedger(filename string, prior map[string]int) (*ledger, error) {
+	billed, err := readBilled(filename, prior)
+	if err != nil {
+		return nil, err
+	}
+	f, err := openLog(filename)
+	if err != nil {
+		return nil, err
+	}
+	l := &ledger{f: f, w: csv.NewWriter(f), billed: billed}
+	if info, err := f.Stat(); err == nil && info.Size() == 0 {
+		l.w.Write(ledgerFields)
+		l.w.Flush()
+	}
+	return l, l.w.Error()
+}
+
+// Records a call of the provider on the image. Failed calls are not billed
+func (l *ledger) record(key, image string, result *ocr.Result, err error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	date := time.Now().UTC().Format("2006-01-02 15:04:05 MST")
+	attempts, status, cost := "", "error", 0.0
+	if err == nil {
+		p, _ := ocr.ProviderByKey(key)
+		cost = p.Pricing.After(l.billed[key], 1)
+		l.billed[key]++
+		attempts, status = strconv.Itoa(result.Attempts), "ok"
+	}
+	l.w.Write([]string{date, key, image, attempts, status, strconv.FormatFloat(cost, 'f', 6, 64)})
+	l.w.Flush()
+	return l.w.Error()
+}
+
+// Closes the ledger and makes it read-only
+func (l *ledger) close() error {
+	return closeLog(l.f)
+}
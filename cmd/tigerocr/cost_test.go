@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestParseBilled(t *testing.T) {
+	billed, err := parseBilled("aws=1200,gcp=800")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(billed) != 2 || billed["aws"] != 1200 || billed["gcp"] != 800 {
+		t.Fatalf("Unexpected billed: %v", billed)
+	}
+	for _, s := range []string{"aws", "aws=-1", "aws=x", "nope=1"} {
+		if _, err := parseBilled(s); err == nil {
+			t.Fatalf("Expected an error for %q", s)
+		}
+	}
+}
+
+func TestReadBilled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := path.Join(dir, "ledger.csv")
+
+	// Only this month's successful calls count, after the prior pages
+	now := time.Now().UTC().Format("2006-01-02 15:04:05 MST")
+	ledger := "date,provider,image,attempts,status,cost\n" +
+		now + ",aws,a.png,1,ok,0.0015\n" +
+		now + ",aws,b.png,,error,0.000000\n" +
+		"2001-01-01 00:00:00 UTC,aws,c.png,1,ok,0.0015\n"
+	if err := ioutil.WriteFile(filename, []byte(ledger), 0600); err != nil {
+		t.Fatal(err)
+	}
+	billed, err := readBilled(filename, map[string]int{"aws": 10, "gcp": 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if billed["aws"] != 11 || billed["gcp"] != 5 {
+		t.Fatalf("Unexpected billed: %v", billed)
+	}
+
+	billed, err = readBilled(path.Join(dir, "missing.csv"), nil)
+	if err != nil || len(billed) != 0 {
+		t.Fatalf("Expected nothing billed. Received: %v %v", billed, err)
+	}
+}
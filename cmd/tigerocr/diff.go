@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ughe/tigerocr/editdist"
+	"github.com/ughe/tigerocr/normalize"
+)
+
+const (
+	ansiReset  = "\033[0m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+)
+
+// Splits the alignment into rows at the newlines both texts agree on
+func diffRows(ops []editdist.Op) [][]editdist.Op {
+	rows := [][]editdist.Op{nil}
+	for _, op := range ops {
+		if op.Kind == editdist.Match && op.A == "\n" {
+			rows = append(rows, nil)
+			continue
+		}
+		rows[len(rows)-1] = append(rows[len(rows)-1], op)
+	}
+	return rows
+}
+
+// Returns the text of one side of a row with its edits colored, along with
+// the number of visible characters
+func ansiSide(row []editdist.Op, left bool) (string, int) {
+	var s strings.Builder
+	n := 0
+	for _, op := range row {
+		text, col := op.B, ansiGreen // Inserts are on the right
+		if left {
+			text, col = op.A, ansiRed // Deletes are on the left
+		}
+		text = strings.ReplaceAll(text, "\n", "⏎")
+		n += utf8.RuneCountInString(text)
+		switch {
+		case text == "":
+		case op.Kind == editdist.Match:
+			s.WriteString(text)
+		case op.Kind == editdist.Substitute:
+			s.WriteString(ansiYellow + text + ansiReset)
+		default:
+			s.WriteString(col + text + ansiReset)
+		}
+	}
+	return s.String(), n
+}
+
+// Prints the test text on the left and the truth on the right. Deletes are
+// red, inserts green, and substitutions yellow
+func printANSIDiff(ops []editdist.Op) {
+	rows := diffRows(ops)
+	lefts, widths := make([]string, len(rows)), make([]int, len(rows))
+	width := 0
+	for i, row := range rows {
+		lefts[i], widths[i] = ansiSide(row, true)
+		if widths[i] > width {
+			width = widths[i]
+		}
+	}
+	for i, row := range rows {
+		right, _ := ansiSide(row, false)
+		fmt.Printf("%s%s | %s\n", lefts[i], strings.Repeat(" ", width-widths[i]), right)
+	}
+}
+
+func htmlSide(row []editdist.Op, left bool) string {
+	var s strings.Builder
+	for _, op := range row {
+		text, class := op.B, "ins"
+		if left {
+			text, class = op.A, "del"
+		}
+		text = html.EscapeString(text)
+		switch {
+		case text == "":
+		case op.Kind == editdist.Match:
+			s.WriteString(text)
+		case op.Kind == editdist.Substitute:
+			s.WriteString(`<span class="sub">` + text + `</span>`)
+		default:
+			s.WriteString(`<span class="` + class + `">` + text + `</span>`)
+		}
+	}
+	return s.String()
+}
+
+// Prints a standalone html page with the test and truth side by side
+func printHTMLDiff(testName, truthName string, ops []editdist.Op) {
+	fmt.Println(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><style>
+td { font-family: monospace; white-space: pre; padding: 0 1em; }
+.del { background: #fbb; } .ins { background: #bfb; } .sub { background: #ffb; }
+</style></head><body><table>`)
+	fmt.Printf("<tr><th>%s</th><th>%s</th></tr>\n", html.EscapeString(testName), html.EscapeString(truthName))
+	for _, row := range diffRows(ops) {
+		fmt.Printf("<tr><td>%s</td><td>%s</td></tr>\n", htmlSide(row, true), htmlSide(row, false))
+	}
+	fmt.Println("</table></body></html>")
+}
+
+// Returns the pairs of test and truth files. Directories are paired by
+// the names of their .txt files
+func textPairs(testPath, truthPath string) ([][2]string, error) {
+	info, err := os.Stat(testPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return [][2]string{{testPath, truthPath}}, nil
+	}
+	matches, err := filepath.Glob(path.Join(testPath, "*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	pairs := make([][2]string, 0, len(matches))
+	for _, m := range matches {
+		truth := path.Join(truthPath, filepath.Base(m))
+		if _, err := os.Stat(truth); err != nil {
+			fmt.Fprintf(os.Stderr, "[WARNING] No truth for %s\n", m)
+			continue
+		}
+		pairs = append(pairs, [2]string{m, truth})
+	}
+	return pairs, nil
+}
+
+// Prints the confusions of the test files against the truth, most
+// frequent first
+func confusionsCommand(testPath, truthPath string, norm normalize.Normalizer) error {
+	pairs, err := textPairs(testPath, truthPath)
+	if err != nil {
+		return err
+	}
+	counts := make(map[editdist.Confusion]int)
+	for _, p := range pairs {
+		bufa, err := readText(p[0], norm)
+		if err != nil {
+			return err
+		}
+		bufb, err := readText(p[1], norm)
+		if err != nil {
+			return err
+		}
+		ops := editdist.Align([]rune(string(bufa)), []rune(string(bufb)))
+		for _, c := range editdist.Confusions(ops) {
+			counts[c]++
+		}
+	}
+	cs := make([]editdist.Confusion, 0, len(counts))
+	for c := range counts {
+		cs = append(cs, c)
+	}
+	sort.Slice(cs, func(i, j int) bool {
+		if counts[cs[i]] != counts[cs[j]] {
+			return counts[cs[i]] > counts[cs[j]]
+		}
+		return cs[i].A+cs[i].B < cs[j].A+cs[j].B
+	})
+	for _, c := range cs {
+		fmt.Printf("%d\t%q\t%q\n", counts[c], c.A, c.B)
+	}
+	return nil
+}
@@ -3,22 +3,78 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path"
 
 	"github.com/ughe/tigerocr/editdist"
+	"github.com/ughe/tigerocr/normalize"
+	"github.com/ughe/tigerocr/ocr"
 )
 
-func editdistCommand(srcFilename, dstFilename string, cer bool) error {
-	bufa, err := ioutil.ReadFile(srcFilename)
+// Reads the text file and normalizes it. ALTO and PAGE-XML files are read as
+// the plaintext of their layout
+func readText(filename string, norm normalize.Normalizer) ([]byte, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if path.Ext(filename) == ".xml" {
+		detection, err := ocr.DecodeXML(buf)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		buf = []byte(detection.Plaintext())
+	}
+	return norm.Bytes(buf), nil
+}
+
+// Reads the ground truth of the pointer: <ptr>.txt, or the text of an ALTO
+// or PAGE-XML <ptr>.xml
+func readTruth(truthDir, ptr string, norm normalize.Normalizer) ([]byte, error) {
+	buf, err := readText(path.Join(truthDir, ptr+".txt"), norm)
+	if !os.IsNotExist(err) {
+		return buf, err
+	}
+	buf, xerr := readText(path.Join(truthDir, ptr+".xml"), norm)
+	if os.IsNotExist(xerr) {
+		return nil, err // Not found as txt either
+	}
+	return buf, xerr
+}
+
+func editdistCommand(srcFilename, dstFilename string, cer, wer, diff, htm bool, tokens, algo string, norm normalize.Normalizer) error {
+	tokenize, err := editdist.ParseTokenizer(tokens)
 	if err != nil {
 		return err
 	}
-	bufb, err := ioutil.ReadFile(dstFilename)
+	a, err := editdist.ParseAlgorithm(algo)
 	if err != nil {
 		return err
 	}
-	dist := editdist.Levenshtein(bufa, bufb)
+	bufa, err := readText(srcFilename, norm)
+	if err != nil {
+		return err
+	}
+	bufb, err := readText(dstFilename, norm)
+	if err != nil {
+		return err
+	}
+	if diff {
+		ops := editdist.Align([]rune(string(bufa)), []rune(string(bufb)))
+		if htm {
+			printHTMLDiff(srcFilename, dstFilename, ops)
+		} else {
+			printANSIDiff(ops)
+		}
+		return nil
+	}
+	if wer {
+		fmt.Printf("%.5f\n", editdist.WER(string(bufa), string(bufb), tokenize))
+		return nil
+	}
+	dist, blen := a.Distance(bufa, bufb)
 	if cer {
-		fmt.Printf("%.5f\n", editdist.CER(dist, len(bufb)))
+		fmt.Printf("%.5f\n", editdist.CER(dist, blen))
 	} else {
 		fmt.Printf("%d\n", dist)
 	}
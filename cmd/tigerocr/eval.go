@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/ughe/tigerocr/ocr"
+)
+
+// Reads a blw or xml (ALTO or PAGE) file as a detection. Raw json results
+// are rejected since boxes such as Textract's are relative to the image
+// size, which is unknown here. Convert them with their image first
+func readDetection(filename string) (*ocr.Detection, error) {
+	if ext := filepath.Ext(filename); ext != ".blw" && ext != ".xml" {
+		return nil, fmt.Errorf("%s: Expected *.blw or *.xml instead of: %v (convert json results with their image first)", filename, ext)
+	}
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	detection, err := convertToBLW(nil, raw, filename)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return detection, nil
+}
+
+// Reads the ground truth layout of the pointer: <ptr>.blw or <ptr>.xml
+func readTruthLayout(truthDir, ptr string) (*ocr.Detection, error) {
+	var err error
+	for _, ext := range []string{".blw", ".xml"} {
+		var detection *ocr.Detection
+		if detection, err = readDetection(path.Join(truthDir, ptr+ext)); !os.IsNotExist(err) {
+			return detection, err
+		}
+	}
+	return nil, err
+}
+
+// Evaluates each ocr result against the ground truth. Either the truth is a
+// file and the results are files, summarized by algorithm id, or the truth
+// and results are directories of <ptr>.{blw,xml} and <ptr>.<provider>.blw,
+// summarized by provider
+func evalCommand(iou float64, truthName string, predNames []string) error {
+	evals := make(map[string]*ocr.Evaluation)
+	pages := make(map[string]int)
+	add := func(provider string, truth, pred *ocr.Detection) error {
+		e, err := ocr.Evaluate(truth, pred, iou)
+		if err != nil {
+			return err
+		}
+		if evals[provider] == nil {
+			evals[provider] = &ocr.Evaluation{}
+		}
+		evals[provider].Add(e)
+		pages[provider] += len(truth.Pages)
+		return nil
+	}
+
+	info, err := os.Stat(truthName)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		if len(predNames) != 1 {
+			return fmt.Errorf("Expected one ocr directory with a truth directory instead of: %d", len(predNames))
+		}
+		listing, err := ioutil.ReadDir(predNames[0])
+		if err != nil {
+			return err
+		}
+		for _, l := range listing {
+			ptr, provider, ok := benchName(l.Name())
+			if l.IsDir() || !ok {
+				continue
+			} else if filepath.Ext(l.Name()) != ".blw" {
+				fmt.Fprintf(os.Stderr, "[WARNING] Skipping %s: convert json results with their image first\n", l.Name())
+				continue
+			}
+			truth, err := readTruthLayout(truthName, ptr)
+			if os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "[WARNING] No truth for %s\n", l.Name())
+				continue
+			} else if err != nil {
+				return err
+			}
+			pred, err := readDetection(path.Join(predNames[0], l.Name()))
+			if err != nil {
+				return err
+			}
+			if err := add(provider, truth, pred); err != nil {
+				return fmt.Errorf("%s: %v", l.Name(), err)
+			}
+		}
+	} else {
+		truth, err := readDetection(truthName)
+		if err != nil {
+			return err
+		}
+		for _, predName := range predNames {
+			pred, err := readDetection(predName)
+			if err != nil {
+				return err
+			}
+			provider := pred.AlgoID
+			if provider == "" {
+				provider = filepath.Base(predName)
+			}
+			if err := add(provider, truth, pred); err != nil {
+				return fmt.Errorf("%s: %v", predName, err)
+			}
+		}
+	}
+	if len(evals) == 0 {
+		return fmt.Errorf("No ocr results with ground truth in %s", predNames[0])
+	}
+
+	providers := make([]string, 0, len(evals))
+	for p := range evals {
+		providers = append(providers, p)
+	}
+	sort.Strings(providers)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "provider\tpages\ttruth\tpredicted\tmatched\tprecision\trecall\tf1\taccuracy")
+	for _, p := range providers {
+		e := evals[p]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%.4f\t%.4f\t%.4f\t%.4f\n", p, pages[p], e.Truth, e.Predicted, e.Matched, e.Precision(), e.Recall(), e.F1(), e.Accuracy())
+	}
+	w.Flush()
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/ughe/tigerocr/ocr"
+)
+
+func TestReadDetectionRejectsJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Textract boxes are relative to the image: 0.1 of a 1x1 image is 0
+	raw := `{"Blocks": [{"BlockType": "WORD", "Id": "w", "Text": "Hello", "Confidence": 99,
+		"Geometry": {"BoundingBox": {"Left": 0.1, "Top": 0.1, "Width": 0.4, "Height": 0.2}}}]}`
+	result, err := json.Marshal(ocr.Result{Service: "aws", Raw: []byte(raw)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := path.Join(dir, "page.aws.json")
+	if err := ioutil.WriteFile(filename, result, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readDetection(filename); err == nil || !strings.Contains(err.Error(), "*.blw") {
+		t.Fatalf("Expected json to be rejected. Received: %v", err)
+	}
+	if err := evalCommand(0.5, filename, []string{filename}); err == nil {
+		t.Fatal("Expected eval of json to fail")
+	}
+}
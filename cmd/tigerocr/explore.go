@@ -1,16 +1,18 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"math"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -18,192 +20,336 @@ import (
 
 	"github.com/ughe/explorer"
 	"github.com/ughe/tigerocr/editdist"
+	"github.com/ughe/tigerocr/normalize"
 	"github.com/ughe/tigerocr/ocr"
 )
 
 const DPI = 300
 const FMT = "png"
-const QUA = "00" // imagemagick.org/script/command-line-options.php#quality
 
 const FILE_PERM = 0444
 const DIR_PERM = 0755
 
-// Count pages in PDF using GhostScript executable
-func countPages(gs, pdfPath string) (int, error) {
-	cmd := exec.Command(gs, "-q", "-dNOSAFER", "-dNODISPLAY", "-c",
-		"("+pdfPath+") (r) file runpdfbegin pdfpagecount = quit")
-	out, err := cmd.Output()
+type exploreOptions struct {
+	keys     string
+	selected []string // Provider keys
+	sch      *scheduler
+	norm     normalize.Normalizer
+	resume   bool    // Reuse the outputs of a previous run
+	yes      bool    // Run without asking
+	maxCost  float64 // Dollars. Stop if the estimate is higher. Negative for no limit
+	dryRun   bool    // Stop before OCR and print the plan
+	backend  string  // Name of the pdfBackend
+	dpi      int
+	format   string         // Image format: png or jpg
+	pages    string         // Page range, i.e. 5-20. Empty for every page
+	billed   map[string]int // Pages billed this month outside of this explorer, by provider key
+}
+
+// The OCR explore would run, printed by -dry-run
+type explorePlan struct {
+	PDF       string         `json:"pdf"`
+	Dir       string         `json:"dir"`
+	Pages     int            `json:"pages"`
+	Ops       int            `json:"ops"`
+	Estimate  float64        `json:"estimate"`
+	Providers []providerPlan `json:"providers"`
+}
+
+type providerPlan struct {
+	Name     string  `json:"name"`
+	Key      string  `json:"key"`
+	Pages    int     `json:"pages"`
+	Estimate float64 `json:"estimate"`
+}
+
+// Prints the plan as json. Estimates follow the pages billed earlier
+func printPlan(pdfPath, baseDir string, pc int, pages, billed map[string]int, providers []string) error {
+	plan := explorePlan{PDF: pdfPath, Dir: baseDir, Pages: pc, Providers: []providerPlan{}}
+	for _, s := range providers {
+		p, _ := ocr.ProviderByKey(s)
+		cost := p.Pricing.After(billed[s], pages[s])
+		plan.Providers = append(plan.Providers, providerPlan{p.Name, s, pages[s], cost})
+		plan.Ops += pages[s]
+		plan.Estimate += cost
+	}
+	encoded, err := json.MarshalIndent(plan, "", "  ")
 	if err != nil {
-		if exitError, ok := err.(*exec.ExitError); ok {
-			return 0, fmt.Errorf("[ERROR] GhostScript pdfpagecount: %s", string(exitError.Stderr))
-		}
-		return 0, err
+		return err
+	}
+	fmt.Println(string(encoded))
+	return nil
+}
+
+// Marks an explorer directory that only -dry-run has written to
+const dryRunMarker = ".dry-run"
+
+// Returns an error if the explorer directory exists, unless resuming or
+// only a dry run created it. Returns whether the directory is new
+func checkExplorerDir(baseDir string, resume bool) (bool, error) {
+	_, err := os.Stat(baseDir)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if resume || allExist([]string{path.Join(baseDir, dryRunMarker)}) {
+		return false, nil
 	}
-	i, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	return false, fmt.Errorf("Please remove directory: ./%s (or -resume)", baseDir)
+}
+
+// Returns whether to run OCR costing estCost dollars. Asks unless -yes,
+// -max-cost or there is nothing to run
+func confirmCost(opts exploreOptions, estCost float64, nCalls int) (bool, error) {
+	if opts.maxCost >= 0 && estCost > opts.maxCost {
+		return false, fmt.Errorf("Estimate $%.4f (%d ops) exceeds -max-cost $%.4f", estCost, nCalls, opts.maxCost)
+	}
+	if opts.yes || opts.maxCost >= 0 || nCalls == 0 {
+		fmt.Printf("[INFO] Estimate: $%.2f (%d ops). Running\n", estCost, nCalls)
+		return true, nil
+	}
+	fmt.Printf("[ATTN] Estimate: $%.2f (%d ops). Run? [N/y]: \t", estCost, nCalls)
+	var resp string
+	_, err := fmt.Scanln(&resp)
 	if err != nil {
-		return 0, err
+		resp = "" // Treat any err as an empty response
+	}
+	resp = strings.ToLower(strings.TrimSpace(resp))
+	return resp == "y" || resp == "yes", nil
+}
+
+// Writes the read-only file, replacing the file of a previous run
+func writeFileOver(filename string, data []byte) error {
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return err
 	}
-	return i, nil
+	return ioutil.WriteFile(filename, data, FILE_PERM)
 }
 
-// Extract text from PDFs. No functionality for telling if it is useful
-func extractText(gs, pdfPath, dstPath string, pageCount int) error {
+// Returns true if each of the files exists
+func allExist(filenames []string) bool {
+	for _, f := range filenames {
+		if _, err := os.Stat(f); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the pointer of each page, i.e. name-07 for page 8 of name.pdf
+func pagePtrs(pdfPath string, pageCount int) []string {
 	pdfName := strings.TrimSuffix(filepath.Base(pdfPath), filepath.Ext(pdfPath))
 	nDigits := strconv.Itoa(int(math.Ceil(math.Log10(float64(pageCount)))))
-	ptr := pdfName + "-%0" + nDigits + "d"
-	for i := 0; i < pageCount; i++ {
-		is := strconv.Itoa(i + 1) // gs uses page numbers from 1 instead of 0
-		dst := path.Join(dstPath, fmt.Sprintf(ptr, i)+".txt")
-		cmd := exec.Command(gs, "-q", "-sDEVICE=txtwrite", "-dBATCH", "-dNOPAUSE",
-			"-dFirstPage="+is, "-dLastPage="+is, "-sOutputFile="+dst, pdfPath)
-		out, err := cmd.Output()
-		if err != nil {
-			if exitError, ok := err.(*exec.ExitError); ok {
-				return fmt.Errorf("[ERROR] GhostScript txtwrite: %s", string(exitError.Stderr))
-			}
+	ptrs := make([]string, pageCount)
+	for i := range ptrs {
+		ptrs[i] = fmt.Sprintf(pdfName+"-%0"+nDigits+"d", i)
+	}
+	return ptrs
+}
+
+// Extract text from PDFs. No functionality for telling if it is useful.
+// Pages with text from a previous run are skipped
+func extractText(backend pdfBackend, pdfPath, dstPath string, pages []int, ptrs []string) error {
+	for i, page := range pages {
+		dst := path.Join(dstPath, ptrs[i]+".txt")
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		}
+		// Renamed once complete so that interrupted pages are extracted again
+		tmp := dst + ".part"
+		if err := backend.Text(pdfPath, page, tmp); err != nil {
 			return err
 		}
-		stdout := strings.TrimSpace(string(out))
-		if stdout != "" {
-			return fmt.Errorf("[WARNING] GhostScript unexpected stdout: %s", stdout)
+		if err := os.Rename(tmp, dst); err != nil {
+			return err
 		}
-		err = os.Chmod(dst, FILE_PERM)
-		if err != nil {
+		if err := os.Chmod(dst, FILE_PERM); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-// Convert PDF to Image using ImageMagick executable. Returns list of pointers
-func convertPDF(magick []string, dstDir, pdfPath string, pageCount int) ([]string, error) {
-	pdfName := strings.TrimSuffix(filepath.Base(pdfPath), filepath.Ext(pdfPath))
-	nDigits := strconv.Itoa(int(math.Ceil(math.Log10(float64(pageCount)))))
-	ptr := pdfName + "-%0" + nDigits + "d"
-	out := path.Join(dstDir, ptr+"."+FMT)
-	magick = append(magick, "-density", fmt.Sprintf("%d", DPI), "-alpha", "off", "-quality", QUA, pdfPath, out)
-	_, err := exec.Command(magick[0], magick[1:]...).Output()
-	if err != nil {
-		if exitError, ok := err.(*exec.ExitError); ok {
-			return nil, fmt.Errorf("ImageMagick: %s", string(exitError.Stderr))
+// Renders the PDF pages as images named by their pointers. Pages with an
+// image from a previous run are skipped. Returns the number rendered
+func renderPages(backend pdfBackend, pdfPath, dstDir string, pages []int, ptrs []string, dpi int, format string) (int, error) {
+	var pending []int
+	var dsts, tmps []string
+	for i, page := range pages {
+		dst := path.Join(dstDir, ptrs[i]+"."+format)
+		if _, err := os.Stat(dst); err == nil {
+			continue
 		}
-		return nil, err
+		pending = append(pending, page)
+		dsts = append(dsts, dst)
+		// Renamed once complete so that interrupted pages are rendered again
+		tmps = append(tmps, path.Join(dstDir, ptrs[i]+".part."+format))
 	}
-	// Create pointers (matching magick's output format)
-	ptrs := make([]string, pageCount)
-	for i := 0; i < pageCount; i++ {
-		ptrs[i] = fmt.Sprintf(ptr, i)
-		if err := os.Chmod(path.Join(dstDir, ptrs[i]+"."+FMT), FILE_PERM); err != nil {
-			return nil, err
+	if len(pending) == 0 {
+		return 0, nil
+	}
+	if err := backend.Render(pdfPath, pending, dpi, tmps); err != nil {
+		return 0, err
+	}
+	for i, dst := range dsts {
+		if err := os.Rename(tmps[i], dst); err != nil {
+			return i, err
+		}
+		if err := os.Chmod(dst, FILE_PERM); err != nil {
+			return i, err
 		}
 	}
-	return ptrs, nil
+	return len(dsts), nil
+}
+
+// Returns the services that have no json result for the pointer yet
+func pendingServices(ocrDir, ptr string, services map[string]ocr.Client) map[string]ocr.Client {
+	pending := make(map[string]ocr.Client)
+	for s, c := range services {
+		if _, err := os.Stat(path.Join(ocrDir, ptr+"."+s+".json")); err != nil {
+			pending[s] = c
+		}
+	}
+	return pending
+}
+
+// Returns the number of failures listed in ocr-errs.txt by a previous run
+func countFailures(artDir string) int {
+	raw, err := ioutil.ReadFile(path.Join(artDir, "ocr-errs.txt"))
+	if err != nil {
+		return 0
+	}
+	return len(failedRun.FindAll(raw, -1))
+}
+
+// Start of a line of ocr-errs.txt, i.e. name-07.aws.json:Run:
+var failedRun = regexp.MustCompile(`(?m)^\S+\.json:`)
+
+// Opens the read-only log for appending
+func openLog(filename string) (*os.File, error) {
+	os.Chmod(filename, 0644) // Made read-only again once closed
+	return os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, FILE_PERM)
+}
+
+// Closes the log and makes it read-only
+func closeLog(f *os.File) error {
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Chmod(f.Name(), FILE_PERM)
 }
 
-// Executes OCR. Returns map from providers to map from pointer to seconds
-func execOCR(ptrs []string, services map[string]ocr.Client, artDir, imgsDir, ocrDir string) (map[string]map[string]string, error) {
+// Executes OCR of the services without a json result for each pointer.
+// Successes are appended to ocr-logs.txt. Failures replace ocr-errs.txt.
+// Every call is appended to ledger.csv. The logs and ledger are closed
+// even if the run is interrupted, so that it can be resumed
+func execOCR(ctx context.Context, ptrs []string, services map[string]ocr.Client, sch *scheduler, billed map[string]int, artDir, imgsDir, format, ocrDir string) (err error) {
 	os.MkdirAll(artDir, DIR_PERM)
 	os.MkdirAll(ocrDir, DIR_PERM)
 
-	fout, err := os.OpenFile(path.Join(artDir, "ocr-logs.txt"), os.O_CREATE|os.O_WRONLY, FILE_PERM)
+	// Keeps the first error
+	closing := func(close func() error) {
+		if cerr := close(); err == nil {
+			err = cerr
+		}
+	}
+	fout, err := openLog(path.Join(artDir, "ocr-logs.txt"))
 	if err != nil {
-		return nil, err
+		return err
+	}
+	defer closing(func() error { return closeLog(fout) })
+	if err := os.Remove(path.Join(artDir, "ocr-errs.txt")); err != nil && !os.IsNotExist(err) {
+		return err
 	}
-	ferr, err := os.OpenFile(path.Join(artDir, "ocr-errs.txt"), os.O_CREATE|os.O_WRONLY, FILE_PERM)
+	ferr, err := openLog(path.Join(artDir, "ocr-errs.txt"))
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer closing(func() error { return closeLog(ferr) })
 	stdout := log.New(fout, "", 0)
 	stderr := log.New(ferr, "", 0)
+	l, err := openLedger(path.Join(artDir, "ledger.csv"), billed)
+	if err != nil {
+		return err
+	}
+	defer closing(l.close)
+	sched := *sch
+	sched.ledger = l
 
 	// Run each ptr, in order, on each service, in alphabetical order
+	var jobs []ocrJob
 	for _, ptr := range ptrs {
-		imgPath := path.Join(imgsDir, ptr+"."+FMT)
-		err := runOCR(imgPath, ocrDir, stdout, stderr, services)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	if err := fout.Close(); err != nil {
-		return nil, err
-	}
-	if err := ferr.Close(); err != nil {
-		return nil, err
+		imgPath := path.Join(imgsDir, ptr+"."+format)
+		jobs = append(jobs, imageJobs(imgPath, pendingServices(ocrDir, ptr, services))...)
 	}
+	return sched.run(ctx, jobs, ocrDir, stdout, stderr)
+}
 
-	// Read the logs
+// Returns map from providers to map from pointer to seconds of each json
+// result in ocrDir
+func readResults(ocrDir string, ptrs []string, services map[string]ocr.Client) (map[string]map[string]string, error) {
 	results := make(map[string]map[string]string)
 	for s, _ := range services {
 		results[s] = make(map[string]string)
-	}
-
-	fout, err = os.Open(path.Join(artDir, "ocr-logs.txt"))
-	if err != nil {
-		return nil, err
-	}
-	defer fout.Close()
-	scanner := bufio.NewScanner(fout)
-	for scanner.Scan() {
-		line := scanner.Text()
-		fields := strings.Split(line, ":")
-		if len(fields) != 2 {
-			return nil, fmt.Errorf("Expected 1 ':' Found: %s", line)
-		}
-		file, millisStr := fields[0], fields[1]
-		parts := strings.Split(file, ".")
-		if len(parts) != 3 {
-			return nil, fmt.Errorf("Expected 2 '.' Found: %s", file)
-		}
-		ptr, s := parts[0], parts[1]
-		millis, err := strconv.Atoi(millisStr)
-		if err != nil {
-			return nil, err
-		}
-		// Format %.02f without any trailing zeros
-		const MILLIS_PER_SEC = 1000.0
-		secs := fmt.Sprintf("%.02f", float64(millis)/MILLIS_PER_SEC)
-		if secs[len(secs)-3:] == ".00" {
-			secs = secs[:len(secs)-3]
-		} else if secs[len(secs)-1:] == "0" {
-			secs = secs[:len(secs)-1]
-		}
-		if len(secs) == 0 {
-			secs = "0"
+		for _, ptr := range ptrs {
+			raw, err := ioutil.ReadFile(path.Join(ocrDir, ptr+"."+s+".json"))
+			if os.IsNotExist(err) {
+				continue // Failed
+			} else if err != nil {
+				return nil, err
+			}
+			var result ocr.Result
+			if err := json.Unmarshal(raw, &result); err != nil {
+				return nil, err
+			}
+			results[s][ptr] = fmtSecs(result.Duration)
 		}
-		results[s][ptr] = secs
 	}
 	return results, nil
 }
 
+// Formats %.02f without any trailing zeros
+func fmtSecs(millis int64) string {
+	const MILLIS_PER_SEC = 1000.0
+	secs := fmt.Sprintf("%.02f", float64(millis)/MILLIS_PER_SEC)
+	if secs[len(secs)-3:] == ".00" {
+		secs = secs[:len(secs)-3]
+	} else if secs[len(secs)-1:] == "0" {
+		secs = secs[:len(secs)-1]
+	}
+	if len(secs) == 0 {
+		secs = "0"
+	}
+	return secs
+}
+
 // Creates the explorer website
-func createExplorer(ptrs []string, metrics map[string][]string, metricLimits, metricOrder []string, txtDirs, pdfPath, baseDir string) error {
+func createExplorer(ptrs []string, metrics map[string][]string, metricLimits, metricOrder []string, txtDirs, pdfPath, format, baseDir string) error {
 	os.MkdirAll(path.Join(baseDir, "js"), DIR_PERM)
 	os.MkdirAll(path.Join(baseDir, "data"), DIR_PERM)
 
 	// Write Explorer website static files
 	indexDst := path.Join(baseDir, "index.html")
-	if err := ioutil.WriteFile(indexDst, explorer.Index, FILE_PERM); err != nil {
+	if err := writeFileOver(indexDst, explorer.Index); err != nil {
 		return err
 	}
 	styleDst := path.Join(baseDir, "style.css")
-	if err := ioutil.WriteFile(styleDst, explorer.Style, FILE_PERM); err != nil {
+	if err := writeFileOver(styleDst, explorer.Style); err != nil {
 		return err
 	}
 	mainDst := path.Join(baseDir, "js", "main.js")
-	if err := ioutil.WriteFile(mainDst, explorer.Main, FILE_PERM); err != nil {
+	if err := writeFileOver(mainDst, explorer.Main); err != nil {
 		return err
 	}
 	gridDst := path.Join(baseDir, "js", "grid.js")
-	if err := ioutil.WriteFile(gridDst, explorer.Grid, FILE_PERM); err != nil {
+	if err := writeFileOver(gridDst, explorer.Grid); err != nil {
 		return err
 	}
 
 	// Create config.csv
 	configDst := path.Join(baseDir, "data", "config.csv")
 	pdfName := strings.TrimSuffix(filepath.Base(pdfPath), filepath.Ext(pdfPath))
-	config := fmt.Sprintf("title,%s Explorer\nimgs-fmt,%s\ntxts-dirs,%s\n[]links,Data;data/\n[]range,CER;0;1\n%s", pdfName, FMT, txtDirs, strings.Join(metricLimits, "\n"))
-	if err := ioutil.WriteFile(configDst, []byte(config), FILE_PERM); err != nil {
+	config := fmt.Sprintf("title,%s Explorer\nimgs-fmt,%s\ntxts-dirs,%s\n[]links,Data;data/\n[]range,CER;0;1\n%s", pdfName, format, txtDirs, strings.Join(metricLimits, "\n"))
+	if err := writeFileOver(configDst, []byte(config)); err != nil {
 		return err
 	}
 
@@ -215,13 +361,29 @@ func createExplorer(ptrs []string, metrics map[string][]string, metricLimits, me
 		results = append(results, k+","+strings.Join(metrics[k], ","))
 	}
 	resultBytes := []byte(strings.Join(results, "\n"))
-	if err := ioutil.WriteFile(resultsDst, resultBytes, FILE_PERM); err != nil {
+	if err := writeFileOver(resultsDst, resultBytes); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// Returns the Levenshtein distance and word error rate of the normalized
+// text of provider s1 against the text of s2
+func compareTexts(txtsDir, s1, s2, ptr string, norm normalize.Normalizer) (int, float64, error) {
+	bufa, err := readText(path.Join(txtsDir, s1, ptr+".txt"), norm)
+	if err != nil {
+		return 0, 0, err
+	}
+	bufb, err := readText(path.Join(txtsDir, s2, ptr+".txt"), norm)
+	if err != nil {
+		return 0, 0, err
+	}
+	dist, _ := editdist.Runes.Distance(bufa, bufb)
+	wer := editdist.WER(string(bufa), string(bufb), editdist.Fields)
+	return dist, wer, nil
+}
+
 // Union of two []string, similar to unix `comm -12 a b`
 func comm(a, b []string) []string {
 	sort.Strings(a)
@@ -243,36 +405,30 @@ func comm(a, b []string) []string {
 	return c
 }
 
-func exploreCommand(keys string, aws, azu, azuR, gcp bool, pdfPath string) error {
+func exploreCommand(ctx context.Context, opts exploreOptions, pdfPath string) error {
 	// Check pdf file exists
 	if _, err := os.Stat(pdfPath); err != nil {
 		return err
 	}
 
-	// Check for ImageMagick
-	var magick []string
-	if _, err := exec.LookPath("magick"); err == nil {
-		magick = []string{"magick", "convert"}
-	} else if _, err := exec.LookPath("convert"); err == nil {
-		magick = []string{"convert"}
-	} else {
-		return fmt.Errorf("Missing magick convert (imagemagick.org)")
+	if opts.format != "png" && opts.format != "jpg" {
+		return fmt.Errorf("Image format %s is not {png, jpg}", opts.format)
 	}
-
-	// Check for GhostScript
-	var gs string
-	if path, err := exec.LookPath("gs"); err == nil {
-		gs = path
-	} else if path, err := exec.LookPath("gswin32c"); err == nil {
-		gs = path
-	} else if path, err := exec.LookPath("gswin64c"); err == nil {
-		gs = path
-	} else {
-		return fmt.Errorf("Missing gs (ghostscript.com)")
+	if opts.dpi <= 0 {
+		return fmt.Errorf("Expected a positive dpi. Found: %d", opts.dpi)
+	}
+	backend, err := newPDFBackend(opts.backend)
+	if err != nil {
+		return err
+	}
+	// Progress goes to stderr when stdout is the json plan
+	var info io.Writer = os.Stdout
+	if opts.dryRun {
+		info = os.Stderr
 	}
 
 	// Set up OCR Clients
-	services := initServices(keys, aws, azu, azuR, gcp)
+	services := initServices(opts.keys, opts.selected)
 	// Sort the services alphabetically
 	providers := make([]string, 0, len(services))
 	for s, _ := range services {
@@ -283,63 +439,103 @@ func exploreCommand(keys string, aws, azu, azuR, gcp bool, pdfPath string) error
 	// Check if explorer exists
 	pdfName := strings.TrimSuffix(filepath.Base(pdfPath), filepath.Ext(pdfPath))
 	baseDir := fmt.Sprintf("explorer-%s", pdfName)
-	_, err := os.Stat(baseDir)
-	if err == nil || !os.IsNotExist(err) {
-		return fmt.Errorf("Please remove directory: ./%s", baseDir)
+	created, err := checkExplorerDir(baseDir, opts.resume)
+	if err != nil {
+		return err
 	}
 
 	// Get number of pages
-	pc, err := countPages(gs, pdfPath)
+	pc, err := backend.PageCount(pdfPath)
 	if err != nil {
 		return err
 	}
+	pageNums, err := parsePages(opts.pages, pc)
+	if err != nil {
+		return err
+	}
+	// Pointers number every page so that ranges of the pdf share names
+	allPtrs := pagePtrs(pdfPath, pc)
+	ptrs := make([]string, len(pageNums))
+	for i, page := range pageNums {
+		ptrs[i] = allPtrs[page-1]
+	}
 
-	// Convert PDF to images
-	fmt.Printf("[INFO] PDF to %s (Total: %d) ... \t\t", strings.ToUpper(FMT), pc)
+	// Convert PDF to images unless a previous run did
+	fmt.Fprintf(info, "[INFO] PDF to %s (Total: %d) ... \t\t", strings.ToUpper(opts.format), len(ptrs))
 	start := time.Now()
 	imgsDir := path.Join(baseDir, "data", "imgs")
 	os.MkdirAll(imgsDir, DIR_PERM)
-	ptrs, err := convertPDF(magick, imgsDir, pdfPath, pc)
-	if err != nil {
+	if n, err := renderPages(backend, pdfPath, imgsDir, pageNums, ptrs, opts.dpi, opts.format); err != nil {
 		return err
+	} else if n == 0 {
+		fmt.Fprintf(info, "reused\n")
+	} else {
+		secs := int(time.Since(start) / time.Second)
+		fmt.Fprintf(info, "%d secs\n", secs)
 	}
-	secs := int(time.Since(start) / time.Second)
-	fmt.Printf("%d secs\n", secs)
 
 	// Extract text from PDF
 	txtsDir := path.Join(baseDir, "data", "txts")
 	dstPath := path.Join(txtsDir, "pdf")
 	os.MkdirAll(dstPath, DIR_PERM)
-	fmt.Printf("[INFO] PDF to TXT (Total: %d) ... \t\t", pc)
+	fmt.Fprintf(info, "[INFO] PDF to TXT (Total: %d) ... \t\t", len(ptrs))
 	start = time.Now()
-	err = extractText(gs, pdfPath, dstPath, pc)
+	err = extractText(backend, pdfPath, dstPath, pageNums, ptrs)
 	if err != nil {
 		return err
 	}
-	secs = int(time.Since(start) / time.Second)
-	fmt.Printf("%d secs\n", secs)
+	secs := int(time.Since(start) / time.Second)
+	fmt.Fprintf(info, "%d secs\n", secs)
 
-	// Execute OCR
-	estCost := 0.0015
-	nCalls := len(ptrs) * len(services)
-	fmt.Printf("[ATTN] Estimate: $%.2f (%d ops). Run? [N/y]: \t", float64(nCalls)*estCost, nCalls)
-	var resp string
-	_, err = fmt.Scanln(&resp)
+	// Execute OCR of the pages without results
+	artDir := path.Join(baseDir, "data", "artifacts")
+	ocrDir := path.Join(artDir, "json")
+	nCalls := 0
+	pages := make(map[string]int) // Pending pages of each provider
+	for _, ptr := range ptrs {
+		for s := range pendingServices(ocrDir, ptr, services) {
+			pages[s]++
+			nCalls++
+		}
+	}
+	if opts.resume {
+		fmt.Fprintf(info, "[INFO] Resuming: %d of %d ops done. Retrying %d failures\n", len(ptrs)*len(services)-nCalls, len(ptrs)*len(services), countFailures(artDir))
+	}
+	billed, err := readBilled(path.Join(artDir, "ledger.csv"), opts.billed)
 	if err != nil {
-		resp = "" // Treat any err as an empty response
+		return err
 	}
-	resp = strings.ToLower(strings.TrimSpace(resp))
-	if resp != "y" && resp != "yes" {
+	estCost := printEstimates(info, pages, billed)
+	if opts.dryRun {
+		if created {
+			if err := ioutil.WriteFile(path.Join(baseDir, dryRunMarker), nil, 0644); err != nil {
+				return err
+			}
+			fmt.Fprintf(info, "[INFO] Created ./%s, which the next run reuses without -resume\n", baseDir)
+		}
+		return printPlan(pdfPath, baseDir, len(ptrs), pages, billed, providers)
+	}
+	if ok, err := confirmCost(opts, estCost, nCalls); err != nil {
+		return err
+	} else if !ok {
 		fmt.Printf("[DONE] OCR cancelled. Created directory: %s\n", baseDir)
 		return nil
 	}
 
-	fmt.Printf("[INFO] Executing OCR (Total: %d) ... \t\t", len(ptrs)*len(services))
+	// Results are written from here on, so the directory is no longer a dry run's
+	if err := os.Remove(path.Join(baseDir, dryRunMarker)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	fmt.Printf("[INFO] Executing OCR (Total: %d) ... \t\t", nCalls)
 	start = time.Now()
-	artDir := path.Join(baseDir, "data", "artifacts")
-	ocrDir := path.Join(artDir, "json")
-
-	results, err := execOCR(ptrs, services, artDir, imgsDir, ocrDir)
+	// Ctrl-C cancels in-flight OCR requests. Afterwards it exits as usual
+	ocrCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	err = execOCR(ocrCtx, ptrs, services, opts.sch, opts.billed, artDir, imgsDir, opts.format, ocrDir)
+	stop()
+	if err != nil {
+		return err
+	}
+	results, err := readResults(ocrDir, ptrs, services)
 	if err != nil {
 		return err
 	}
@@ -369,9 +565,9 @@ func exploreCommand(keys string, aws, azu, azuR, gcp bool, pdfPath string) error
 	os.MkdirAll(blwDir, DIR_PERM)
 	for s, ptr_ := range results {
 		for ptr, _ := range ptr_ {
-			img := path.Join(imgsDir, ptr+"."+FMT)
+			img := path.Join(imgsDir, ptr+"."+opts.format)
 			jsn := path.Join(ocrDir, ptr+"."+s+"."+"json")
-			if _, err := convert(img, jsn, blwDir); err != nil {
+			if _, err := convert(img, jsn, blwDir, "blw"); err != nil {
 				return err
 			}
 		}
@@ -398,7 +594,7 @@ func exploreCommand(keys string, aws, azu, azuR, gcp bool, pdfPath string) error
 			if err := json.Unmarshal(raw, &detection); err != nil {
 				return err
 			}
-			if err := ioutil.WriteFile(path.Join(sDir, ptr+".txt"), []byte(detection.Plaintext()), FILE_PERM); err != nil {
+			if err := writeFileOver(path.Join(sDir, ptr+".txt"), []byte(detection.Plaintext())); err != nil {
 				return err
 			}
 		}
@@ -445,17 +641,14 @@ func exploreCommand(keys string, aws, azu, azuR, gcp bool, pdfPath string) error
 			// Run between provider i and i+1
 			s1, s2 := strings.ToUpper(providers[i]), strings.ToUpper(providers[(i+1)%len(providers)])
 			levs := make([]string, 0, len(unified))
+			wers := make([]string, 0, len(unified))
 			for _, ptr := range unified {
-				bufa, err := ioutil.ReadFile(path.Join(txtsDir, s1, ptr+".txt"))
+				dist, wer, err := compareTexts(txtsDir, s1, s2, ptr, opts.norm)
 				if err != nil {
 					return err
 				}
-				bufb, err := ioutil.ReadFile(path.Join(txtsDir, s2, ptr+".txt"))
-				if err != nil {
-					return err
-				}
-				dist := editdist.Levenshtein(bufa, bufb)
 				levs = append(levs, strconv.Itoa(dist))
+				wers = append(wers, fmt.Sprintf("%.4f", wer))
 				if firstLoop {
 					minl, maxl = dist, dist
 					firstLoop = false
@@ -467,24 +660,22 @@ func exploreCommand(keys string, aws, azu, azuR, gcp bool, pdfPath string) error
 			}
 			name := fmt.Sprintf("%s vs %s", s1, s2)
 			metrics[name] = levs
-			metricOrder = append(metricOrder, name)
+			metrics["WER "+name] = wers
+			metricOrder = append(metricOrder, name, "WER "+name)
 		}
 	}
 	// Compare PDF to first provider
 	levs := make([]string, 0, len(unified))
+	wers := make([]string, 0, len(unified))
 	s1 := strings.ToUpper(providers[0])
 	s2 := "PDF"
 	for _, ptr := range unified {
-		bufa, err := ioutil.ReadFile(path.Join(txtsDir, s1, ptr+".txt"))
+		dist, wer, err := compareTexts(txtsDir, s1, s2, ptr, opts.norm)
 		if err != nil {
 			return err
 		}
-		bufb, err := ioutil.ReadFile(path.Join(txtsDir, s2, ptr+".txt"))
-		if err != nil {
-			return err
-		}
-		dist := editdist.Levenshtein(bufa, bufb)
 		levs = append(levs, strconv.Itoa(dist))
+		wers = append(wers, fmt.Sprintf("%.4f", wer))
 		if firstLoop {
 			minl, maxl = dist, dist
 			firstLoop = false
@@ -496,8 +687,11 @@ func exploreCommand(keys string, aws, azu, azuR, gcp bool, pdfPath string) error
 	}
 	name := fmt.Sprintf("%s vs %s", s1, s2)
 	metrics[name] = levs
-	metricOrder = append(metricOrder, name)
+	metrics["WER "+name] = wers
+	metricOrder = append(metricOrder, name, "WER "+name)
 
+	// WER rows also contain " vs ". Ranges match in order so WER goes first
+	metricLimits = append(metricLimits, "WER;0;1")
 	metricLimits = append(metricLimits, fmt.Sprintf(" vs ;%d;%d", minl, maxl))
 	secs = int(time.Since(start) / time.Second)
 	fmt.Printf("%d secs\n", secs) // Finished Levenshtein
@@ -580,7 +774,7 @@ func exploreCommand(keys string, aws, azu, azuR, gcp bool, pdfPath string) error
 
 	// Create explorer
 	fmt.Printf("[INFO] Creating Explorer ... \t\t\t")
-	err = createExplorer(unified, metrics, metricLimits, metricOrder, txtDirs, pdfPath, baseDir)
+	err = createExplorer(unified, metrics, metricLimits, metricOrder, txtDirs, pdfPath, opts.format, baseDir)
 	fmt.Printf("done\n")
 
 	fmt.Printf("[INFO] Comparable Ptrs: %d (out of %d). %s\n", len(unified), len(ptrs), strings.Join(res, ", "))
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// Backend that writes part of the text and then fails if broken
+type fakeBackend struct {
+	broken bool
+}
+
+func (fakeBackend) PageCount(string) (int, error) { return 1, nil }
+
+func (fakeBackend) Render(string, []int, int, []string) error { return nil }
+
+func (b fakeBackend) Text(_ string, _ int, dst string) error {
+	if err := ioutil.WriteFile(dst, []byte("trunc"), 0600); err != nil {
+		return err
+	}
+	if b.broken {
+		return errors.New("interrupted")
+	}
+	return ioutil.WriteFile(dst, []byte("truncated no more"), 0600)
+}
+
+func TestExtractTextResumes(t *testing.T) {
+	dir := t.TempDir()
+	dst := path.Join(dir, "book-1.txt")
+	if err := extractText(fakeBackend{broken: true}, "book.pdf", dir, []int{1}, []string{"book-1"}); err == nil {
+		t.Fatal("Expected the broken backend to fail")
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Fatalf("Expected no text for the failed page. Received: %v", err)
+	}
+	if err := extractText(fakeBackend{}, "book.pdf", dir, []int{1}, []string{"book-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if raw, err := ioutil.ReadFile(dst); err != nil || string(raw) != "truncated no more" {
+		t.Fatalf("Expected the whole text. Received: %q %v", raw, err)
+	}
+}
+
+// Answers the prompt of confirmCost with input
+func withStdin(t *testing.T, input string, f func()) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString(input)
+	w.Close()
+	defer func(stdin *os.File) { os.Stdin = stdin }(os.Stdin)
+	os.Stdin = r
+	f()
+	r.Close()
+}
+
+func TestConfirmCost(t *testing.T) {
+	cases := []struct {
+		opts   exploreOptions
+		cost   float64
+		calls  int
+		input  string // Answer to the prompt
+		want   bool
+		errMsg string
+	}{
+		{exploreOptions{maxCost: 1}, 1.5, 10, "y\n", false, "exceeds -max-cost"},
+		{exploreOptions{maxCost: 0, yes: true}, 0.01, 1, "", false, "exceeds -max-cost"},
+		{exploreOptions{maxCost: 1}, 1, 10, "", true, ""}, // Within budget without asking
+		{exploreOptions{maxCost: 0}, 0, 10, "", true, ""},
+		{exploreOptions{maxCost: -1, yes: true}, 100, 10, "", true, ""},
+		{exploreOptions{maxCost: -1}, 5, 0, "", true, ""}, // Nothing to run
+		{exploreOptions{maxCost: -1}, 5, 10, "Yes\n", true, ""},
+		{exploreOptions{maxCost: -1}, 5, 10, "n\n", false, ""},
+		{exploreOptions{maxCost: -1}, 5, 10, "", false, ""}, // No answer
+	}
+	for i, c := range cases {
+		withStdin(t, c.input, func() {
+			ok, err := confirmCost(c.opts, c.cost, c.calls)
+			if c.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), c.errMsg) {
+					t.Fatalf("Case %d: Expected error %q. Received: %v", i, c.errMsg, err)
+				}
+			} else if err != nil || ok != c.want {
+				t.Fatalf("Case %d: Expected %v. Received: %v %v", i, c.want, ok, err)
+			}
+		})
+	}
+}
+
+func TestCheckExplorerDir(t *testing.T) {
+	dir := path.Join(t.TempDir(), "explorer-book")
+	if created, err := checkExplorerDir(dir, false); err != nil || !created {
+		t.Fatalf("Expected a new directory. Received: %v %v", created, err)
+	}
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := checkExplorerDir(dir, false); err == nil {
+		t.Fatal("Expected an existing directory to need -resume")
+	}
+	if created, err := checkExplorerDir(dir, true); err != nil || created {
+		t.Fatalf("Expected -resume to reuse the directory. Received: %v %v", created, err)
+	}
+	// A directory only a dry run wrote to is reused without -resume
+	if err := ioutil.WriteFile(path.Join(dir, dryRunMarker), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if created, err := checkExplorerDir(dir, false); err != nil || created {
+		t.Fatalf("Expected the dry run's directory reused. Received: %v %v", created, err)
+	}
+}
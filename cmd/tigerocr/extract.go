@@ -3,9 +3,11 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
+
+	"github.com/ughe/tigerocr/ocr"
 )
 
-func extractCommand(filename string, stat, algoid, speed, date, text bool) error {
+func extractCommand(filename string, stat, algoid, speed, date, text, ordered bool) error {
 	raw, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return err
@@ -23,9 +25,13 @@ func extractCommand(filename string, stat, algoid, speed, date, text bool) error
 		fmt.Printf("algoid: %s\n", detection.AlgoID)
 		fmt.Printf("millis: %d\n", detection.Millis)
 		fmt.Printf("date:   %s\n", detection.Date)
+		fmt.Printf("pages:  %d\n", len(detection.Pages))
 		fmt.Printf("blocks: %d\n", b)
 		fmt.Printf("lines:  %d\n", l)
 		fmt.Printf("words:  %d\n", w)
+		if mean, lo, n := detection.Confidence(); n > 0 {
+			fmt.Printf("conf:   %.4f mean, %.4f min (%d words)\n", mean, lo, n)
+		}
 	} else if algoid {
 		fmt.Printf("%s\n", detection.AlgoID)
 	} else if speed {
@@ -33,6 +39,11 @@ func extractCommand(filename string, stat, algoid, speed, date, text bool) error
 	} else if date {
 		fmt.Printf("%s\n", detection.Date)
 	} else if text {
+		if ordered {
+			if detection, err = ocr.ReadingOrder(detection); err != nil {
+				return err
+			}
+		}
 		fmt.Printf("%s\n", detection.Plaintext())
 	} else {
 		return fmt.Errorf("Error: no flags specified")
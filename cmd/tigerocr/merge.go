@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/ughe/tigerocr/ocr"
+)
+
+// Merges the blw or json files into a consensus blw named after the image
+func mergeCommand(strategy, imgFilename string, filenames []string) error {
+	s, err := ocr.ParseMergeStrategy(strategy)
+	if err != nil {
+		return err
+	}
+	img, err := ioutil.ReadFile(imgFilename)
+	if err != nil {
+		return err
+	}
+
+	blws := make([]ocr.Detection, 0, len(filenames))
+	for _, filename := range filenames {
+		raw, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		detection, err := convertToBLW(img, raw, filename)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		blws = append(blws, *detection)
+	}
+
+	merged, err := ocr.Merge(blws, s)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+
+	dstFilename := strings.TrimSuffix(filepath.Base(imgFilename), filepath.Ext(imgFilename)) + ".merged.blw"
+	if err := ioutil.WriteFile(dstFilename, encoded, 0600); err != nil {
+		return err
+	}
+	fmt.Printf("[INFO] Merged %d detections (%s): %v\n", len(blws), merged.AlgoID, dstFilename)
+	return nil
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"fmt"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ughe/tigerocr/pdf"
+)
+
+// Renders the pages of a PDF as images and extracts their embedded text.
+// Pages are numbered from 1
+type pdfBackend interface {
+	PageCount(pdfPath string) (int, error)
+	// Writes each page as an image to the dst of the same index, of the format
+	// of its extension
+	Render(pdfPath string, pages []int, dpi int, dsts []string) error
+	// Writes the text embedded in the page to dst
+	Text(pdfPath string, page int, dst string) error
+}
+
+// Backends by -backend name. Each returns an error if its tools are missing
+var pdfBackends = map[string]func() (pdfBackend, error){
+	"go":      newGoBackend,
+	"magick":  newMagickBackend,
+	"poppler": newPopplerBackend,
+}
+
+// Returns the backend names for usage messages, i.e. go|magick|poppler
+func pdfBackendNames() string {
+	names := make([]string, 0, len(pdfBackends))
+	for name := range pdfBackends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, "|")
+}
+
+// Returns the named backend
+func newPDFBackend(name string) (pdfBackend, error) {
+	newBackend, ok := pdfBackends[name]
+	if !ok {
+		return nil, fmt.Errorf("Backend %s is not {%s}", name, pdfBackendNames())
+	}
+	backend, err := newBackend()
+	if err != nil && name != "go" {
+		return nil, fmt.Errorf("%v. Or use -backend=go, which needs no tools", err)
+	}
+	return backend, err
+}
+
+// Runs the command. Returns its stdout, or its stderr as an error
+func runTool(tool string, args ...string) ([]byte, error) {
+	out, err := exec.Command(tool, args...).Output()
+	if err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("%s: %s", filepath.Base(tool), strings.TrimSpace(string(exitError.Stderr)))
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// Renders with ImageMagick and reads text with GhostScript
+type magickBackend struct {
+	magick []string
+	gs     string
+}
+
+func newMagickBackend() (pdfBackend, error) {
+	// Check for ImageMagick
+	var magick []string
+	if _, err := exec.LookPath("magick"); err == nil {
+		magick = []string{"magick", "convert"}
+	} else if _, err := exec.LookPath("convert"); err == nil {
+		magick = []string{"convert"}
+	} else {
+		return nil, fmt.Errorf("Missing magick convert (imagemagick.org)")
+	}
+
+	// Check for GhostScript
+	var gs string
+	if path, err := exec.LookPath("gs"); err == nil {
+		gs = path
+	} else if path, err := exec.LookPath("gswin32c"); err == nil {
+		gs = path
+	} else if path, err := exec.LookPath("gswin64c"); err == nil {
+		gs = path
+	} else {
+		return nil, fmt.Errorf("Missing gs (ghostscript.com)")
+	}
+	return magickBackend{magick, gs}, nil
+}
+
+// Count pages in PDF using GhostScript executable
+func (b magickBackend) PageCount(pdfPath string) (int, error) {
+	out, err := runTool(b.gs, "-q", "-dNOSAFER", "-dNODISPLAY", "-c",
+		"("+pdfPath+") (r) file runpdfbegin pdfpagecount = quit")
+	if err != nil {
+		return 0, fmt.Errorf("[ERROR] GhostScript pdfpagecount: %v", err)
+	}
+	return strconv.Atoi(strings.TrimSpace(string(out)))
+}
+
+// Quality is the png compression level, or the jpg quality.
+// imagemagick.org/script/command-line-options.php#quality
+func magickQuality(dst string) string {
+	if ext := strings.ToLower(filepath.Ext(dst)); ext == ".jpg" || ext == ".jpeg" {
+		return "90"
+	}
+	return "00"
+}
+
+// Renders every page in one run since each run reads the whole PDF. The
+// pages are numbered in order from 0 in a temporary directory and then moved
+func (b magickBackend) Render(pdfPath string, pages []int, dpi int, dsts []string) error {
+	if len(pages) == 0 {
+		return nil
+	}
+	tmp, err := ioutil.TempDir(filepath.Dir(dsts[0]), ".render")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	indexes := make([]string, len(pages))
+	for i, page := range pages {
+		indexes[i] = strconv.Itoa(page - 1) // magick numbers pages from 0
+	}
+	ext := filepath.Ext(dsts[0])
+	args := append(append([]string{}, b.magick[1:]...), "-density", strconv.Itoa(dpi), "-alpha", "off", "-quality", magickQuality(dsts[0]),
+		fmt.Sprintf("%s[%s]", pdfPath, strings.Join(indexes, ",")), "+adjoin", "-scene", "0", path.Join(tmp, "%d"+ext))
+	if _, err := runTool(b.magick[0], args...); err != nil {
+		return err
+	}
+	for i, dst := range dsts {
+		if err := os.Rename(path.Join(tmp, strconv.Itoa(i)+ext), dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b magickBackend) Text(pdfPath string, page int, dst string) error {
+	is := strconv.Itoa(page)
+	out, err := runTool(b.gs, "-q", "-sDEVICE=txtwrite", "-dBATCH", "-dNOPAUSE",
+		"-dFirstPage="+is, "-dLastPage="+is, "-sOutputFile="+dst, pdfPath)
+	if err != nil {
+		return fmt.Errorf("[ERROR] GhostScript txtwrite: %v", err)
+	}
+	if stdout := strings.TrimSpace(string(out)); stdout != "" {
+		return fmt.Errorf("[WARNING] GhostScript unexpected stdout: %s", stdout)
+	}
+	return nil
+}
+
+// Renders and reads text with the poppler-utils pdfinfo, pdftoppm and
+// pdftotext. Needs neither ImageMagick nor GhostScript
+type popplerBackend struct{}
+
+func newPopplerBackend() (pdfBackend, error) {
+	for _, tool := range []string{"pdfinfo", "pdftoppm", "pdftotext"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return nil, fmt.Errorf("Missing %s (poppler.freedesktop.org)", tool)
+		}
+	}
+	return popplerBackend{}, nil
+}
+
+var pdfinfoPages = regexp.MustCompile(`(?m)^Pages:\s+(\d+)`)
+
+func (popplerBackend) PageCount(pdfPath string) (int, error) {
+	out, err := runTool("pdfinfo", pdfPath)
+	if err != nil {
+		return 0, err
+	}
+	m := pdfinfoPages.FindSubmatch(out)
+	if m == nil {
+		return 0, fmt.Errorf("pdfinfo: no page count for %s", pdfPath)
+	}
+	return strconv.Atoi(string(m[1]))
+}
+
+// Renders one page per run since pdftoppm reads only the pages it renders
+func (popplerBackend) Render(pdfPath string, pages []int, dpi int, dsts []string) error {
+	for i, page := range pages {
+		ext := filepath.Ext(dsts[i])
+		device := "-png"
+		if e := strings.ToLower(ext); e == ".jpg" || e == ".jpeg" {
+			device = "-jpeg"
+		}
+		// pdftoppm adds its own extension (.png or .jpg) to the root
+		is := strconv.Itoa(page)
+		if _, err := runTool("pdftoppm", device, "-r", strconv.Itoa(dpi), "-f", is, "-l", is, "-singlefile",
+			pdfPath, strings.TrimSuffix(dsts[i], ext)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (popplerBackend) Text(pdfPath string, page int, dst string) error {
+	is := strconv.Itoa(page)
+	_, err := runTool("pdftotext", "-layout", "-f", is, "-l", is, pdfPath, dst)
+	return err
+}
+
+// Renders and reads text in process with the pdf package. Draws only the
+// images of each page, so it suits scanned books but not born-digital PDFs
+type goBackend struct {
+	mu      sync.Mutex
+	pdfPath string
+	r       *pdf.Reader // Reader of pdfPath, which is kept open
+}
+
+func newGoBackend() (pdfBackend, error) {
+	return &goBackend{}, nil
+}
+
+// Returns the reader of pdfPath, opening it if not the last one opened
+func (b *goBackend) open(pdfPath string) (*pdf.Reader, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.r == nil || b.pdfPath != pdfPath {
+		r, err := pdf.Open(pdfPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", pdfPath, err)
+		}
+		b.pdfPath, b.r = pdfPath, r
+	}
+	return b.r, nil
+}
+
+func (b *goBackend) PageCount(pdfPath string) (int, error) {
+	r, err := b.open(pdfPath)
+	if err != nil {
+		return 0, err
+	}
+	return r.NumPage(), nil
+}
+
+func (b *goBackend) Render(pdfPath string, pages []int, dpi int, dsts []string) error {
+	r, err := b.open(pdfPath)
+	if err != nil {
+		return err
+	}
+	for i, page := range pages {
+		img, err := r.RenderPage(page, float64(dpi))
+		if err != nil {
+			return err
+		}
+		f, err := os.Create(dsts[i])
+		if err != nil {
+			return err
+		}
+		if ext := strings.ToLower(filepath.Ext(dsts[i])); ext == ".jpg" || ext == ".jpeg" {
+			err = jpeg.Encode(f, img, &jpeg.Options{Quality: 90})
+		} else {
+			err = png.Encode(f, img)
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *goBackend) Text(pdfPath string, page int, dst string) error {
+	r, err := b.open(pdfPath)
+	if err != nil {
+		return err
+	}
+	text, err := r.PageText(page)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, []byte(text), 0644)
+}
+
+// Parses a page range such as 5-20, 3, or 1-2,7- (open ended). Returns the
+// pages, from 1, in order. The empty string is every page
+func parsePages(s string, pageCount int) ([]int, error) {
+	selected := make([]bool, pageCount+1)
+	if s == "" {
+		s = "1-"
+	}
+	for _, r := range strings.Split(s, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(r), "-", 2)
+		lo, err := strconv.Atoi(bounds[0])
+		hi := lo
+		if err == nil && len(bounds) == 2 {
+			if bounds[1] == "" {
+				hi = pageCount
+			} else {
+				hi, err = strconv.Atoi(bounds[1])
+			}
+		}
+		if err != nil || lo < 1 || hi < lo {
+			return nil, fmt.Errorf("Expected pages such as 5-20. Found: %s", r)
+		}
+		if hi > pageCount {
+			return nil, fmt.Errorf("Pages %s are past the last page %d", r, pageCount)
+		}
+		for p := lo; p <= hi; p++ {
+			selected[p] = true
+		}
+	}
+	var pages []int
+	for p, ok := range selected {
+		if ok {
+			pages = append(pages, p)
+		}
+	}
+	return pages, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+func TestParsePages(t *testing.T) {
+	cases := []struct {
+		s    string
+		want []int
+	}{
+		{"", []int{1, 2, 3, 4, 5}},
+		{"2-4", []int{2, 3, 4}},
+		{"3", []int{3}},
+		{"4-", []int{4, 5}},
+		{"5-5", []int{5}},
+		{"4-5, 1-2,2", []int{1, 2, 4, 5}},
+	}
+	for _, c := range cases {
+		got, err := parsePages(c.s, 5)
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%q: Expected %v. Received: %v %v", c.s, c.want, got, err)
+		}
+	}
+	for _, s := range []string{"0", "4-6", "6-", "3-2", "x", "1-x", "-2", ","} {
+		if got, err := parsePages(s, 5); err == nil {
+			t.Fatalf("%q: Expected an error. Received: %v", s, got)
+		}
+	}
+}
+
+func TestGoBackend(t *testing.T) {
+	// A page of text under a black image on its left half
+	img := image.NewGray(image.Rect(0, 0, 10, 10))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	doc := gofpdf.New("P", "pt", "", "")
+	doc.AddPageFormat("P", gofpdf.SizeType{Wd: 100, Ht: 50})
+	doc.SetFont("Courier", "", 10)
+	doc.Text(10, 20, "scanned text")
+	opt := gofpdf.ImageOptions{ImageType: "png"}
+	doc.RegisterImageOptionsReader("scan", opt, &buf)
+	doc.ImageOptions("scan", 0, 0, 50, 50, false, opt, 0, "")
+	dir := t.TempDir()
+	pdfPath := path.Join(dir, "book.pdf")
+	if err := doc.OutputFileAndClose(pdfPath); err != nil {
+		t.Fatal(err)
+	}
+
+	backend, err := newPDFBackend("go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := backend.PageCount(pdfPath); err != nil || n != 1 {
+		t.Fatalf("Expected 1 page. Received: %d %v", n, err)
+	}
+	dst := path.Join(dir, "book-1.png")
+	if err := backend.Render(pdfPath, []int{1}, 144, []string{dst}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	page, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := page.Bounds(); b.Dx() != 200 || b.Dy() != 100 {
+		t.Fatalf("Expected 200x100 at 144 dpi. Received: %v", b)
+	}
+	if g := color.GrayModel.Convert(page.At(50, 50)).(color.Gray).Y; g != 0 {
+		t.Fatalf("Expected the black image. Received: %d", g)
+	}
+	if g := color.GrayModel.Convert(page.At(150, 50)).(color.Gray).Y; g != 255 {
+		t.Fatalf("Expected a white background. Received: %d", g)
+	}
+	txt := path.Join(dir, "book-1.txt")
+	if err := backend.Text(pdfPath, 1, txt); err != nil {
+		t.Fatal(err)
+	}
+	if raw, err := ioutil.ReadFile(txt); err != nil || string(raw) != "scanned text\n" {
+		t.Fatalf("Expected the embedded text. Received: %q %v", raw, err)
+	}
+}
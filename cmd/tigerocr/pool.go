@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ughe/tigerocr/ocr"
+)
+
+// Token bucket allowing rate requests per second on average, in bursts of
+// up to one second's worth of requests
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(rps float64) *limiter {
+	burst := math.Max(1, math.Floor(rps))
+	return &limiter{rate: rps, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Takes a token, waiting for one if there are none. Returns ctx.Err() if
+// ctx is done first
+func (l *limiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens-- // Reserved even if it has not been refilled yet
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Returns the key of the provider with the given flag name or key
+func providerKey(s string) (string, bool) {
+	for _, p := range ocr.Providers() {
+		if p.Name == s || p.Key == s {
+			return p.Key, true
+		}
+	}
+	return "", false
+}
+
+// Parses per provider requests per second, i.e. aws=5,gcp=10
+func parseRPS(s string) (map[string]*limiter, error) {
+	limits := make(map[string]*limiter)
+	if s == "" {
+		return limits, nil
+	}
+	for _, kv := range strings.Split(s, ",") {
+		fields := strings.SplitN(kv, "=", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("Expected provider=rps. Found: %s", kv)
+		}
+		key, ok := providerKey(fields[0])
+		if !ok {
+			return nil, fmt.Errorf("Provider %s is not registered", fields[0])
+		}
+		rps, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || rps <= 0 {
+			return nil, fmt.Errorf("Expected a positive rps for %s. Found: %s", fields[0], fields[1])
+		}
+		limits[key] = newLimiter(rps)
+	}
+	return limits, nil
+}
+
+// Bounds the OCR requests of run and explore
+type scheduler struct {
+	parallel int                 // Requests in flight. 0 for one per service
+	limits   map[string]*limiter // By provider key. Unlimited if missing
+	timeout  time.Duration       // Per request. 0 for none
+	ledger   *ledger             // Optional. Records each request
+}
+
+// A request of one service on one image
+type ocrJob struct {
+	imgPath string
+	service string
+	client  ocr.Client
+}
+
+// Returns a job for each service on the image, in alphabetical order
+func imageJobs(imgPath string, services map[string]ocr.Client) []ocrJob {
+	keys := make([]string, 0, len(services))
+	for s := range services {
+		keys = append(keys, s)
+	}
+	sort.Strings(keys)
+	jobs := make([]ocrJob, 0, len(keys))
+	for _, s := range keys {
+		jobs = append(jobs, ocrJob{imgPath, s, services[s]})
+	}
+	return jobs
+}
+
+// Runs the jobs of each service in order, with at most parallel in flight
+// in all, or one per service if parallel is 0. Each service waits for its
+// rate limit before taking a slot, so that a slow service does not hold up
+// the others. Results are written to dstPath as <image>.<service>.json.
+// Successes are logged to stdout and failures to stderr. Returns ctx.Err()
+// if ctx is done
+func (sch *scheduler) run(ctx context.Context, jobs []ocrJob, dstPath string, stdout, stderr *log.Logger) error {
+	var services []string
+	queues := make(map[string][]ocrJob)
+	for _, job := range jobs {
+		if _, ok := queues[job.service]; !ok {
+			services = append(services, job.service)
+		}
+		queues[job.service] = append(queues[job.service], job)
+	}
+	var sem chan bool // Shared by every service if parallel
+	if sch.parallel > 0 {
+		sem = make(chan bool, sch.parallel)
+	}
+
+	var wg sync.WaitGroup
+	for _, s := range services {
+		slots := sem
+		if slots == nil {
+			slots = make(chan bool, 1)
+		}
+		wg.Add(1)
+		go func(queue []ocrJob, slots chan bool) {
+			defer wg.Done()
+			for _, job := range queue {
+				if l, ok := sch.limits[job.service]; ok {
+					if err := l.wait(ctx); err != nil {
+						return // Interrupted
+					}
+				}
+				select {
+				case slots <- true:
+				case <-ctx.Done():
+					return
+				}
+				wg.Add(1)
+				go func(job ocrJob) {
+					defer func() {
+						<-slots
+						wg.Done()
+					}()
+					sch.runJob(ctx, job, dstPath, stdout, stderr)
+				}(job)
+			}
+		}(queues[s], slots)
+	}
+	wg.Wait()
+	// Sucess (even if sub-services error) unless interrupted
+	return ctx.Err()
+}
+
+// Runs the job and logs its result. log.Logger is thread safe:
+// https://golang.org/pkg/log/#Logger
+func (sch *scheduler) runJob(ctx context.Context, job ocrJob, dstPath string, stdout, stderr *log.Logger) {
+	baseName := strings.TrimSuffix(filepath.Base(job.imgPath), filepath.Ext(job.imgPath)) // Strip image extension
+	namepath := path.Join(dstPath, baseName+"."+job.service+".json")
+	img, err := ioutil.ReadFile(job.imgPath)
+	if err != nil {
+		stderr.Printf("%s:ReadFile:%v\n", filepath.Base(namepath), err)
+		return
+	}
+	name, result, err := runService(ctx, img, job.client, namepath, sch.timeout)
+	if err != nil {
+		stderr.Printf("%v\n", err)
+	} else {
+		stdout.Printf("%s:%v\n", name, result.Duration)
+	}
+	if sch.ledger != nil {
+		if err := sch.ledger.record(job.service, filepath.Base(job.imgPath), result, err); err != nil {
+			stderr.Printf("%s:Ledger:%v\n", filepath.Base(namepath), err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ughe/tigerocr/ocr"
+)
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(20)
+	start := time.Now()
+	for i := 0; i < 22; i++ { // A burst of 20, then two at 20 per second
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("Expected about 100ms. Received: %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := newLimiter(0.001).wait(ctx); err != nil {
+		t.Fatalf("Expected the first token without waiting. Received: %v", err)
+	}
+	l = newLimiter(0.001)
+	l.wait(ctx)
+	if err := l.wait(ctx); err != context.Canceled {
+		t.Fatalf("Expected cancellation. Received: %v", err)
+	}
+}
+
+func TestParseRPS(t *testing.T) {
+	limits, err := parseRPS("aws=5,gcp=0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(limits) != 2 || limits["aws"].rate != 5 || limits["gcp"].burst != 1 {
+		t.Fatalf("Unexpected limits: %v", limits)
+	}
+	if limits, err := parseRPS(""); err != nil || len(limits) != 0 {
+		t.Fatalf("Expected no limits. Received: %v %v", limits, err)
+	}
+	for _, s := range []string{"aws", "aws=0", "aws=x", "nope=1"} {
+		if _, err := parseRPS(s); err == nil {
+			t.Fatalf("Expected an error for %q", s)
+		}
+	}
+}
+
+// Client that records the requests in flight
+type fakeClient struct {
+	mu       sync.Mutex
+	inFlight int
+	most     int
+	calls    int
+	delay    time.Duration
+}
+
+func (c *fakeClient) Run(image []byte) (*ocr.Result, error) {
+	return c.RunContext(context.Background(), image)
+}
+
+func (c *fakeClient) RunContext(ctx context.Context, image []byte) (*ocr.Result, error) {
+	c.mu.Lock()
+	c.inFlight++
+	c.calls++
+	if c.inFlight > c.most {
+		c.most = c.inFlight
+	}
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.inFlight--
+		c.mu.Unlock()
+	}()
+	select {
+	case <-time.After(c.delay):
+		return &ocr.Result{Service: "Fake"}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *fakeClient) ResultToDetection(*ocr.Result, int, int) (*ocr.Detection, error) {
+	return nil, errors.New("unused")
+}
+
+// Returns jobs of the services on n images in a temporary directory
+func fakeJobs(t *testing.T, n int, services map[string]ocr.Client) (string, []ocrJob) {
+	dir := t.TempDir()
+	var jobs []ocrJob
+	for i := 0; i < n; i++ {
+		img := path.Join(dir, string(rune('a'+i))+".png")
+		if err := ioutil.WriteFile(img, []byte("image"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		jobs = append(jobs, imageJobs(img, services)...)
+	}
+	return dir, jobs
+}
+
+func TestSchedulerOnePerService(t *testing.T) {
+	a, b := &fakeClient{delay: 5 * time.Millisecond}, &fakeClient{delay: 5 * time.Millisecond}
+	dir, jobs := fakeJobs(t, 6, map[string]ocr.Client{"aws": a, "gcp": b})
+	quiet := log.New(ioutil.Discard, "", 0)
+	if err := (&scheduler{}).run(context.Background(), jobs, dir, quiet, quiet); err != nil {
+		t.Fatal(err)
+	}
+	if a.calls != 6 || b.calls != 6 || a.most != 1 || b.most != 1 {
+		t.Fatalf("Expected 6 calls, one at a time. Received: %+v %+v", a, b)
+	}
+	if _, err := os.Stat(path.Join(dir, "a.aws.json")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchedulerRateLimitHoldsNoSlot(t *testing.T) {
+	slow, fast := &fakeClient{}, &fakeClient{}
+	dir, jobs := fakeJobs(t, 8, map[string]ocr.Client{"aws": slow, "gcp": fast})
+	quiet := log.New(ioutil.Discard, "", 0)
+	sch := &scheduler{parallel: 2, limits: map[string]*limiter{"aws": newLimiter(1)}}
+
+	// aws runs once a second. gcp must not wait for it
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	err := sch.run(ctx, jobs, dir, quiet, quiet)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Expected the deadline to interrupt aws. Received: %v", err)
+	}
+	if fast.calls != 8 || slow.calls != 1 {
+		t.Fatalf("Expected 8 gcp and 1 aws calls. Received: %d %d", fast.calls, slow.calls)
+	}
+}
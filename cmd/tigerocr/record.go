@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ughe/tigerocr/ocr"
+)
+
+// Executes OCR like run and records the responses of each provider as
+// fixtures (<image>.<svc>.fixture.json) that can be replayed in tests
+func recordCommand(ctx context.Context, keys string, providers []string, timeout time.Duration, filenames []string) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	services := initServices(keys, providers)
+	for _, filename := range filenames {
+		img, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		baseName := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+		for _, s := range providers {
+			rc, ok := services[s].(ocr.Recordable)
+			if !ok {
+				return fmt.Errorf("%s: provider cannot be recorded", s)
+			}
+			r := &ocr.Recorder{}
+			dst := path.Join(wd, baseName+"."+s+".json")
+			name, result, err := runService(ctx, img, rc.WithRecorder(r), dst, timeout)
+			if err != nil {
+				return err
+			}
+			fixture := path.Join(wd, baseName+"."+s+".fixture.json")
+			if err := r.Save(fixture); err != nil {
+				return err
+			}
+			fmt.Printf("%s:%v\n[INFO] Recorded %d responses: %s\n", name, result.Duration, len(r.Fixtures()), filepath.Base(fixture))
+		}
+	}
+	return nil
+}
@@ -1,87 +1,60 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
-	"path"
 	"path/filepath"
-	"strings"
+	"time"
 
 	"github.com/ughe/tigerocr/ocr"
 )
 
-func runService(image []byte, Service ocr.Client, dst string) (string, int64, error) {
+func runService(ctx context.Context, image []byte, Service ocr.Client, dst string, timeout time.Duration) (string, *ocr.Result, error) {
 	name := filepath.Base(dst)
-	result, err := Service.Run(image)
-	if err != nil {
-		return "", 0, fmt.Errorf("%s:Run:%v", name, err)
-	}
-
-	encoded, err := json.Marshal(result)
-	if err != nil {
-		return "", 0, fmt.Errorf("%s:Marshal:%v", name, err)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
-
-	err = ioutil.WriteFile(dst, encoded, 0600)
+	result, err := Service.RunContext(ctx, image)
 	if err != nil {
-		return "", 0, fmt.Errorf("%s:WriteFile:%v", name, err)
+		return "", nil, fmt.Errorf("%s:Run:%v", name, err)
 	}
-	return name, result.Duration, nil
-}
 
-func runOCR(imgPath, dstPath string, stdout, stderr *log.Logger, services map[string]ocr.Client) error {
-	buf, err := ioutil.ReadFile(imgPath)
+	encoded, err := json.Marshal(result)
 	if err != nil {
-		return err
+		return "", nil, fmt.Errorf("%s:Marshal:%v", name, err)
 	}
 
-	ch := make(chan bool, len(services))
-	for s, Service := range services {
-		baseName := strings.TrimSuffix(filepath.Base(imgPath), filepath.Ext(imgPath)) // Strip image extension
-		namepath := path.Join(dstPath, baseName+"."+s+".json")
-		// log.Logger is thread safe: https://golang.org/pkg/log/#Logger
-		go func(img []byte, Service ocr.Client, p string) {
-			name, duration, err := runService(img, Service, p)
-			if err != nil {
-				stderr.Printf("%v\n", err)
-			} else {
-				stdout.Printf("%s:%v\n", name, duration)
-			}
-			ch <- true
-		}(buf, Service, namepath)
+	// Renamed once complete so that a partial result is not taken for done
+	tmp := dst + ".part"
+	if err := ioutil.WriteFile(tmp, encoded, 0600); err != nil {
+		return "", nil, fmt.Errorf("%s:WriteFile:%v", name, err)
 	}
-	// Wait for each service to finish
-	for i := 0; i < len(services); i++ {
-		<-ch
+	if err := os.Rename(tmp, dst); err != nil {
+		return "", nil, fmt.Errorf("%s:Rename:%v", name, err)
 	}
-	// Sucess (even if sub-services error)
-	return nil
+	return name, result, nil
 }
 
-// Return clients for each service. Map keys will appear in output files
-func initServices(keys string, aws, azu, azuR, gcp bool) map[string]ocr.Client {
-	m := make(map[string]ocr.Client, 3)
-	if aws {
-		m["aws"] = ocr.AWSClient{CredentialsPath: keys}
-	}
-	if azu {
-		m["azu"] = ocr.AzureClient{CredentialsPath: keys}
-	}
-	if azuR {
-		m["azuR"] = ocr.AzureReadClient{CredentialsPath: keys}
-	}
-	if gcp {
-		m["gcp"] = ocr.GCPClient{CredentialsPath: keys}
+// Return clients for each selected provider key. Map keys will appear in output files
+func initServices(keys string, providers []string) map[string]ocr.Client {
+	m := make(map[string]ocr.Client, len(providers))
+	for _, k := range providers {
+		if p, ok := ocr.ProviderByKey(k); ok {
+			m[k] = p.New(keys)
+		}
 	}
 	return m
 }
 
 // Executes OCR for each of the services on each filename
-func runCommand(keys string, aws, azu, azuR, gcp bool, filenames []string) error {
-	m := initServices(keys, aws, azu, azuR, gcp)
+func runCommand(ctx context.Context, keys string, providers []string, sch *scheduler, filenames []string) error {
+	m := initServices(keys, providers)
 
 	wd, err := os.Getwd()
 	if err != nil {
@@ -91,19 +64,15 @@ func runCommand(keys string, aws, azu, azuR, gcp bool, filenames []string) error
 	stdout := log.New(os.Stdout, "", 0)
 	stderr := log.New(os.Stderr, "", 0)
 
-	errs := make([]error, 0)
+	var jobs []ocrJob
+	pages := make(map[string]int)
 	for _, filename := range filenames {
-		if err := runOCR(filename, wd, stdout, stderr, m); err != nil {
-			errs = append(errs, err)
-		}
-	}
-
-	if len(errs) > 0 {
-		allErr := errs[0]
-		for err := range errs[1:] {
-			allErr = fmt.Errorf("%v\n\n%v", allErr, err)
+		for _, job := range imageJobs(filename, m) {
+			jobs = append(jobs, job)
+			pages[job.service]++
 		}
-		return allErr
 	}
-	return nil
+	// Results are on stdout
+	printEstimates(os.Stderr, pages, nil)
+	return sch.run(ctx, jobs, wd, stdout, stderr)
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -10,11 +11,15 @@ import (
 	_ "image/png"
 	"log"
 	"os"
+	"os/signal"
 	"os/user"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
+	"github.com/ughe/tigerocr/editdist"
+	"github.com/ughe/tigerocr/normalize"
 	"github.com/ughe/tigerocr/ocr"
 )
 
@@ -51,19 +56,11 @@ func convertToBLW(img []byte, raw []byte, rawName string) (*ocr.Detection, error
 		if err := json.Unmarshal(raw, &result); err != nil {
 			return nil, err
 		}
-		var c ocr.Client
-		switch result.Service {
-		case "AWS":
-			c = ocr.AWSClient{CredentialsPath: ""}
-		case "Azure":
-			c = ocr.AzureClient{CredentialsPath: ""}
-		case "AzureRead":
-			c = ocr.AzureReadClient{CredentialsPath: ""}
-		case "GCP":
-			c = ocr.GCPClient{CredentialsPath: ""}
-		default:
-			return nil, fmt.Errorf("Service %v is not {AWS, Azure, AzureRead, GCP}", result.Service)
+		p, ok := ocr.ProviderByService(result.Service)
+		if !ok {
+			return nil, fmt.Errorf("Service %v is not a registered provider", result.Service)
 		}
+		c := p.New("")
 		if img == nil {
 			bogus := new(bytes.Buffer)
 			err := jpeg.Encode(bogus, image.NewRGBA(image.Rect(0, 0, 1, 1)), nil)
@@ -77,11 +74,65 @@ func convertToBLW(img []byte, raw []byte, rawName string) (*ocr.Detection, error
 			return nil, err
 		}
 		return c.ResultToDetection(&result, width, height)
+	case ".xml":
+		return ocr.DecodeXML(raw)
 	default:
-		return nil, fmt.Errorf("Expected *.json or *.blw coordinate file instead of: %v", filepath.Ext(rawName))
+		return nil, fmt.Errorf("Expected *.json, *.blw or *.xml coordinate file instead of: %v", filepath.Ext(rawName))
 	}
 }
 
+// Returns the registered providers that pass every filter
+func providers(filters ...func(ocr.Provider) bool) []ocr.Provider {
+	var ps []ocr.Provider
+next:
+	for _, p := range ocr.Providers() {
+		for _, keep := range filters {
+			if !keep(p) {
+				continue next
+			}
+		}
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+// Whether the provider's responses can be recorded as fixtures
+func recordable(p ocr.Provider) bool {
+	_, ok := p.New("").(ocr.Recordable)
+	return ok
+}
+
+// Defines a -<name> flag for each registered provider that passes every
+// filter. Returns map from provider key to flag value
+func providerFlags(set *flag.FlagSet, filters ...func(ocr.Provider) bool) map[string]*bool {
+	m := make(map[string]*bool)
+	for _, p := range providers(filters...) {
+		m[p.Key] = set.Bool(p.Name, false, p.Usage)
+	}
+	return m
+}
+
+// Returns the keys of the selected providers in alphabetical order
+func selected(flags map[string]*bool) []string {
+	keys := make([]string, 0, len(flags))
+	for k, v := range flags {
+		if *v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Returns the provider flags for usage messages, i.e. [-aws] [-gcp]
+func providerUsage(filters ...func(ocr.Provider) bool) string {
+	var names []string
+	for _, p := range providers(filters...) {
+		names = append(names, "[-"+p.Name+"]")
+	}
+	return strings.Join(names, " ")
+}
+
 func main() {
 	// run command
 	runSet := flag.NewFlagSet("run", flag.ExitOnError)
@@ -90,22 +141,25 @@ func main() {
 		log.Fatalf("Failed to read user's directory: %v", err)
 	}
 	keys := runSet.String("keys", path.Join(usr.HomeDir, ".aws"), "Path to credentials directory")
-	aws_ref := "https://docs.aws.amazon.com/textract/latest/dg/setup-awscli-sdk.html"
-	aws_help := "Key files: credentials config\nMore info: " + aws_ref
-	awso := runSet.Bool("aws", false, "Run AWS Textract OCR. "+aws_help)
-	azu_ref := "https://docs.microsoft.com/azure/cognitive-services/cognitive-services-apis-create-account"
-	azu_ins := "\nNote: Create a json file with 'subscription_key' and 'endpoint' items"
-	azu_help := "Key file: azure.json\nMore info: " + azu_ref + azu_ins
-	azuo := runSet.Bool("azure", false, "Run Azure CognitiveServices OCR. "+azu_help)
-	azuRo := runSet.Bool("azureR", false, "Run Azure CognitiveServices Read API.")
-	gcp_ref := "https://cloud.google.com/vision/docs/before-you-begin"
-	gcp_help := "Key file: gcp.json\nMore info: " + gcp_ref
-	gcpo := runSet.Bool("gcp", false, "Run GCP Vision OCR. "+gcp_help)
+	runo := providerFlags(runSet)
+	timeout := runSet.Duration("timeout", 0, "Deadline for each provider on each image, i.e. 30s (0 for none)")
+	parallel := runSet.Int("parallel", 0, "Maximum requests in flight (0 for one per provider)")
+	rps := runSet.String("rps", "", "Requests per second of each provider, i.e. aws=5,gcp=10 (none for unlimited)")
 	runSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, "usage: %s %s [-keys=~/keydir/] [-aws] [-azure] [-gcp] image.jpg\n\n", os.Args[0], os.Args[1])
+		fmt.Fprintf(os.Stderr, "usage: %s %s [-keys=~/keydir/] %s image.jpg\n\n", os.Args[0], os.Args[1], providerUsage())
 		runSet.PrintDefaults()
 	}
 
+	// record command
+	recordSet := flag.NewFlagSet("record", flag.ExitOnError)
+	rkeys := recordSet.String("keys", path.Join(usr.HomeDir, ".aws"), "Path to credentials directory")
+	recordo := providerFlags(recordSet, recordable) // Not local, which sends no requests
+	rtimeout := recordSet.Duration("timeout", 0, "Deadline for each provider on each image, i.e. 30s (0 for none)")
+	recordSet.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s %s [-keys=~/keydir/] %s image.jpg\n\n", os.Args[0], os.Args[1], providerUsage(recordable))
+		recordSet.PrintDefaults()
+	}
+
 	// annotate command
 	annotateSet := flag.NewFlagSet("annotate", flag.ExitOnError)
 	bo := annotateSet.Bool("b", false, "Annotate blocks on original image")
@@ -119,8 +173,17 @@ func main() {
 	// editdist command
 	editdistSet := flag.NewFlagSet("editdist", flag.ExitOnError)
 	cero := editdistSet.Bool("c", false, "Output character error rate instead of levenshtein dist")
+	wero := editdistSet.Bool("w", false, "Output word error rate instead of levenshtein dist")
+	tokens := editdistSet.String("tokens", "fields", "Word tokens for -w: fields (split on whitespace) or punct (punctuation marks are words)")
+	algo := editdistSet.String("algo", "bytes", "Levenshtein algorithm: bytes, runes (unicode characters), or banded (runes, faster on similar texts)")
+	diffo := editdistSet.Bool("diff", false, "Output a side by side diff of the test (left) and truth (right)")
+	htmlo := editdistSet.Bool("html", false, "Output the -diff as html instead of colored text")
+	confo := editdistSet.Bool("confusions", false, "Output counts of confused text, i.e. rn for m. Directories are paired by .txt names")
+	enorm := editdistSet.String("normalize", "", "Normalize both texts first: comma separated nfkc, dehyphen, case, punct, ws or default ("+normalize.Default+")")
 	editdistSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, "usage: %s %s [-c] test.txt truth.txt\n\n", os.Args[0], os.Args[1])
+		fmt.Fprintf(os.Stderr, "usage: %s %s [-c] [-w] [-tokens=fields|punct] [-algo=bytes|runes|banded] test.txt truth.txt\n", os.Args[0], os.Args[1])
+		fmt.Fprintf(os.Stderr, "usage: %s %s -diff [-html] test.txt truth.txt\n", os.Args[0], os.Args[1])
+		fmt.Fprintf(os.Stderr, "usage: %s %s -confusions test/ truth/\n\n", os.Args[0], os.Args[1])
 		editdistSet.PrintDefaults()
 	}
 
@@ -128,8 +191,9 @@ func main() {
 	convertSet := flag.NewFlagSet("convert", flag.ExitOnError)
 	diro := convertSet.Bool("pdf", false, "Convert to PDF. Same arguments as directories, not files")
 	filter := convertSet.String("select", "", "Select BLW prefix for PDF. i.e. -pdf -select=azu for *.azu.blw")
+	to := convertSet.String("to", "blw", "Output format: blw, hocr or alto")
 	convertSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, "usage: %s %s img.jpg ocr.json\nusage: %s %s -pdf imgs/ blw/\n\n", os.Args[0], os.Args[1], os.Args[0], os.Args[1])
+		fmt.Fprintf(os.Stderr, "usage: %s %s [-to=blw|hocr|alto] img.jpg ocr.json\nusage: %s %s -pdf imgs/ blw/\n\n", os.Args[0], os.Args[1], os.Args[0], os.Args[1])
 		convertSet.PrintDefaults()
 	}
 
@@ -140,23 +204,62 @@ func main() {
 	speedo := extractSet.Bool("speed", false, "Speed is the duration in milliseconds to run OCR")
 	dateo := extractSet.Bool("date", false, "Date the OCR was run")
 	texto := extractSet.Bool("text", false, "OCR transcription in plaintext")
+	orderedo := extractSet.Bool("ordered", false, "With -text, reorder lines to read down each column in turn")
 	extractSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, "usage: %s %s [-stat] [-algoid] [-speed] [-date] [-text] ocr.blw\n\n", os.Args[0], os.Args[1])
+		fmt.Fprintf(os.Stderr, "usage: %s %s [-stat] [-algoid] [-speed] [-date] [-text [-ordered]] ocr.blw\n\n", os.Args[0], os.Args[1])
 		extractSet.PrintDefaults()
 	}
 
+	// merge command
+	mergeSet := flag.NewFlagSet("merge", flag.ExitOnError)
+	strategy := mergeSet.String("strategy", "vote", "Merge strategy: vote (strict majority of providers), best (single most agreeing provider), or union (all words)")
+	mergeSet.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s %s [-strategy=vote|best|union] image.jpg a.aws.blw b.azu.json ...\n\n", os.Args[0], os.Args[1])
+		mergeSet.PrintDefaults()
+	}
+
 	// explore command
 	exploreSet := flag.NewFlagSet("explore", flag.ExitOnError)
 	xkeys := exploreSet.String("keys", path.Join(usr.HomeDir, ".aws"), "Path to credentials directory")
-	xawso := exploreSet.Bool("aws", false, "Run AWS Textract OCR. "+aws_help)
-	xazuo := exploreSet.Bool("azure", false, "Run Azure CognitiveServices OCR. "+azu_help)
-	xazuRo := exploreSet.Bool("azureR", false, "Run Azure CognitiveServices Read API.")
-	xgcpo := exploreSet.Bool("gcp", false, "Run GCP Vision OCR. "+gcp_help)
+	exploreo := providerFlags(exploreSet)
+	xtimeout := exploreSet.Duration("timeout", 0, "Deadline for each provider on each page, i.e. 30s (0 for none)")
+	xparallel := exploreSet.Int("parallel", 0, "Maximum requests in flight (0 for one per provider)")
+	xrps := exploreSet.String("rps", "", "Requests per second of each provider, i.e. aws=5,gcp=10 (none for unlimited)")
+	resume := exploreSet.Bool("resume", false, "Resume a previous run: reuse its images and results, and retry its failures")
+	yes := exploreSet.Bool("yes", false, "Run OCR without asking to confirm the estimate")
+	maxCost := exploreSet.Float64("max-cost", -1, "Stop before OCR if the estimate is more dollars than this, otherwise run without asking (negative for no limit)")
+	dryRun := exploreSet.Bool("dry-run", false, "Stop before OCR and print the plan as json")
+	billedFlag := exploreSet.String("billed", "", "Pages each provider already billed this month outside of this explorer, i.e. aws=1200,gcp=800. Estimates and ledger costs follow the price tiers after them and this explorer's ledger.csv")
+	backend := exploreSet.String("backend", "magick", "Renders the pdf and reads its text: "+pdfBackendNames())
+	dpi := exploreSet.Int("dpi", DPI, "Resolution of the page images")
+	imgFmt := exploreSet.String("fmt", FMT, "Format of the page images: png or jpg")
+	pageRange := exploreSet.String("pages", "", "Pages to run, i.e. 5-20 or 1-3,7 (empty for all)")
+	xnorm := exploreSet.String("normalize", "", "Normalize texts before comparing them: comma separated nfkc, dehyphen, case, punct, ws or default ("+normalize.Default+")")
 	exploreSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, "usage: %s %s [-keys=~/keydir/] [-aws] [-azure] [-gcp] file.pdf\n\n", os.Args[0], os.Args[1])
+		fmt.Fprintf(os.Stderr, "usage: %s %s [-keys=~/keydir/] %s file.pdf\n\n", os.Args[0], os.Args[1], providerUsage())
 		exploreSet.PrintDefaults()
 	}
 
+	// bench command
+	benchSet := flag.NewFlagSet("bench", flag.ExitOnError)
+	truth := benchSet.String("truth", "", "Directory of ground truth text files (<ptr>.txt)")
+	ocrDir := benchSet.String("ocr", "", "Directory of ocr results (<ptr>.<provider>.blw or .json)")
+	bout := benchSet.String("o", "results.csv", "Output results.csv for the explorer")
+	balgo := benchSet.String("algo", "runes", "Levenshtein algorithm: runes, banded, or bytes")
+	bnorm := benchSet.String("normalize", "", "Normalize texts before comparing them: comma separated nfkc, dehyphen, case, punct, ws or default ("+normalize.Default+")")
+	benchSet.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s %s -truth truths/ -ocr blw/ [-o results.csv]\n\n", os.Args[0], os.Args[1])
+		benchSet.PrintDefaults()
+	}
+
+	// eval command
+	evalSet := flag.NewFlagSet("eval", flag.ExitOnError)
+	iou := evalSet.Float64("iou", 0.5, "Least intersection over union of the boxes of matching words")
+	evalSet.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s %s [-iou=0.5] truth.blw ocr.blw ...\nusage: %s %s [-iou=0.5] truths/ blw/\n\n", os.Args[0], os.Args[1], os.Args[0], os.Args[1])
+		evalSet.PrintDefaults()
+	}
+
 	// serve command
 	serveSet := flag.NewFlagSet("serve", flag.ExitOnError)
 	serveSet.Usage = func() {
@@ -166,13 +269,17 @@ func main() {
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "usage: %s <command> [arguments]\n\nThe commands are:\n\n"+
-			strings.Repeat("\t%v\n", 7)+"\n", os.Args[0],
+			strings.Repeat("\t%v\n", 11)+"\n", os.Args[0],
 			"run     \t execute ocr on selected providers",
+			"record  \t execute ocr and save provider responses as test fixtures",
 			"annotate\t draw bounding boxes of words on the original image",
-			"editdist\t calculate levenshtein distance of two text files",
+			"editdist\t calculate levenshtein distance (or CER / WER) of two text files",
 			"convert \t convert json ocr responses to unified blw format (*)",
 			"extract \t extract metadata from a blw or json datafile",
+			"merge   \t merge ocr results of several providers into one blw",
 			"explore \t execute pdf ocr and output results as a web explorer",
+			"bench   \t score ocr results against ground truth text",
+			"eval    \t score word boxes and text against ground truth layouts",
 			"serve   \t serve current directory at "+addr,
 		)
 		flag.PrintDefaults()
@@ -190,12 +297,35 @@ func main() {
 			runSet.Usage()
 			os.Exit(1)
 		}
-		if !*awso && !*azuo && !*azuRo && !*gcpo {
+		if len(selected(runo)) == 0 {
 			fmt.Fprintf(os.Stderr, "Error: No service(s) selected.\n")
 			runSet.Usage()
 			os.Exit(1)
 		}
-		err = runCommand(*keys, *awso, *azuo, *azuRo, *gcpo, runSet.Args())
+		limits, lerr := parseRPS(*rps)
+		if lerr != nil {
+			err = lerr
+		} else {
+			sch := &scheduler{parallel: *parallel, limits: limits, timeout: *timeout}
+			// Ctrl-C cancels in-flight OCR requests
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			err = runCommand(ctx, *keys, selected(runo), sch, runSet.Args())
+			stop()
+		}
+	case "record":
+		recordSet.Parse(os.Args[2:])
+		if recordSet.NArg() < 1 {
+			recordSet.Usage()
+			os.Exit(1)
+		}
+		if len(selected(recordo)) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: No service(s) selected.\n")
+			recordSet.Usage()
+			os.Exit(1)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		err = recordCommand(ctx, *rkeys, selected(recordo), *rtimeout, recordSet.Args())
+		stop()
 	case "annotate":
 		annotateSet.Parse(os.Args[2:])
 		if annotateSet.NArg() != 2 {
@@ -213,7 +343,14 @@ func main() {
 		}
 		srcFilename := editdistSet.Arg(0)
 		dstFilename := editdistSet.Arg(1)
-		err = editdistCommand(srcFilename, dstFilename, *cero)
+		norm, nerr := normalize.Parse(*enorm)
+		if nerr != nil {
+			err = nerr
+		} else if *confo {
+			err = confusionsCommand(srcFilename, dstFilename, norm)
+		} else {
+			err = editdistCommand(srcFilename, dstFilename, *cero, *wero, *diffo, *htmlo, *tokens, *algo, norm)
+		}
 	case "convert":
 		convertSet.Parse(os.Args[2:])
 		if convertSet.NArg() != 2 {
@@ -226,7 +363,7 @@ func main() {
 			// Accepts directories instead of filenames
 			err = convertCommandPDF(imgFilename, jsnFilename, *filter)
 		} else {
-			err = convertCommand(imgFilename, jsnFilename)
+			err = convertCommand(imgFilename, jsnFilename, *to)
 		}
 	case "extract":
 		extractSet.Parse(os.Args[2:])
@@ -245,21 +382,81 @@ func main() {
 			extractSet.Usage()
 			os.Exit(1)
 		}
+		if *orderedo && !*texto {
+			fmt.Fprintf(os.Stderr, "Error: -ordered requires -text.\n\n")
+			extractSet.Usage()
+			os.Exit(1)
+		}
 		dataFilename := extractSet.Arg(0)
-		err = extractCommand(dataFilename, *stato, *algoido, *speedo, *dateo, *texto)
+		err = extractCommand(dataFilename, *stato, *algoido, *speedo, *dateo, *texto, *orderedo)
+	case "merge":
+		mergeSet.Parse(os.Args[2:])
+		if mergeSet.NArg() < 3 {
+			mergeSet.Usage()
+			os.Exit(1)
+		}
+		err = mergeCommand(*strategy, mergeSet.Arg(0), mergeSet.Args()[1:])
 	case "explore":
 		exploreSet.Parse(os.Args[2:])
 		if exploreSet.NArg() != 1 {
 			exploreSet.Usage()
 			os.Exit(1)
 		}
-		if !*xawso && !*xazuo && !*xazuRo && !*xgcpo {
+		if len(selected(exploreo)) == 0 {
 			fmt.Fprintf(os.Stderr, "Error: No service(s) selected.\n")
 			exploreSet.Usage()
 			os.Exit(1)
 		}
 		pdfName := exploreSet.Arg(0)
-		err = exploreCommand(*xkeys, *xawso, *xazuo, *xazuRo, *xgcpo, pdfName)
+		norm, nerr := normalize.Parse(*xnorm)
+		billed, berr := parseBilled(*billedFlag)
+		limits, lerr := parseRPS(*xrps)
+		if nerr != nil {
+			err = nerr
+		} else if lerr != nil {
+			err = lerr
+		} else if berr != nil {
+			err = berr
+		} else {
+			opts := exploreOptions{
+				keys:     *xkeys,
+				selected: selected(exploreo),
+				sch:      &scheduler{parallel: *xparallel, limits: limits, timeout: *xtimeout},
+				norm:     norm,
+				resume:   *resume,
+				yes:      *yes,
+				maxCost:  *maxCost,
+				dryRun:   *dryRun,
+				backend:  *backend,
+				dpi:      *dpi,
+				format:   *imgFmt,
+				pages:    *pageRange,
+				billed:   billed,
+			}
+			err = exploreCommand(context.Background(), opts, pdfName)
+		}
+	case "bench":
+		benchSet.Parse(os.Args[2:])
+		if benchSet.NArg() != 0 || *truth == "" || *ocrDir == "" {
+			benchSet.Usage()
+			os.Exit(1)
+		}
+		a, aerr := editdist.ParseAlgorithm(*balgo)
+		norm, nerr := normalize.Parse(*bnorm)
+		if aerr != nil {
+			err = aerr
+		} else if nerr != nil {
+			err = nerr
+		} else {
+			err = benchCommand(*truth, *ocrDir, *bout, a, norm)
+		}
+	case "eval":
+		evalSet.Parse(os.Args[2:])
+		if evalSet.NArg() < 2 {
+			evalSet.Usage()
+			os.Exit(1)
+		}
+		err = evalCommand(*iou, evalSet.Arg(0), evalSet.Args()[1:])
 	case "serve":
 		serveSet.Parse(os.Args[2:])
 		if serveSet.NArg() > 1 {
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestRecordableFlags(t *testing.T) {
+	set := flag.NewFlagSet("record", flag.ContinueOnError)
+	flags := providerFlags(set, recordable)
+	if _, ok := flags["loc"]; ok || set.Lookup("local") != nil {
+		t.Fatal("Expected no -local flag since local sends no requests to record")
+	}
+	if _, ok := flags["aws"]; !ok {
+		t.Fatal("Expected an -aws flag")
+	}
+}
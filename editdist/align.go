@@ -0,0 +1,195 @@
+package editdist
+
+type OpKind int
+
+const (
+	Match      OpKind = iota
+	Insert            // Rune of b missing from a
+	Delete            // Rune of a missing from b
+	Substitute        // Rune of a in place of a rune of b
+)
+
+// Edit of a single rune. A is empty for inserts and B for deletes
+type Op struct {
+	Kind OpKind
+	A    string
+	B    string
+}
+
+// Distance matrix restricted to the cells within k of the diagonal
+type band struct {
+	k     int
+	cells [][]int32
+}
+
+func (m *band) at(i, j int) int {
+	d := j - i + m.k
+	if d < 0 || d > 2*m.k {
+		return m.k + 1
+	}
+	return int(m.cells[i][d])
+}
+
+func newBand(a []rune, b []rune, k int) *band {
+	m := &band{k, make([][]int32, len(a)+1)}
+	inf := k + 1
+	for i := range m.cells {
+		m.cells[i] = make([]int32, 2*k+1)
+		for d := range m.cells[i] {
+			j := i + d - k
+			v := inf
+			if j < 0 || j > len(b) {
+				// Outside of the matrix
+			} else if i == 0 {
+				v = j // First row
+			} else if j == 0 {
+				v = i // First col
+			} else {
+				del := m.at(i-1, j) + 1
+				ins := m.at(i, j-1) + 1
+				sub := m.at(i-1, j-1)
+				if a[i-1] != b[j-1] {
+					sub += 1
+				}
+				v = min(sub, min(del, ins))
+			}
+			m.cells[i][d] = int32(min(v, inf))
+		}
+	}
+	return m
+}
+
+// Most cells a band may have, 64 MB, before aligning in linear space instead
+var maxBandCells = 1 << 24
+
+// Returns the edits turning a into b, in order, matches included. Only the
+// band around the diagonal is kept so similar texts need little memory.
+// Dissimilar texts whose band would be too large are aligned by
+// Hirschberg's algorithm, in space linear in their length
+func Align(a []rune, b []rune) []Op {
+	ops := make([]Op, 0, max(len(a), len(b)))
+	if m := fitBand(a, b); m != nil {
+		return backtrack(a, b, m, ops)
+	}
+	return hirschberg(a, b, ops)
+}
+
+// Returns the smallest band holding the distance, or nil if that band
+// would have more than maxBandCells cells
+func fitBand(a []rune, b []rune) *band {
+	k := max(1, max(len(a)-len(b), len(b)-len(a)))
+	// A band as wide as the longer text covers the whole matrix
+	n := max(len(a), len(b))
+	for {
+		if (len(a)+1)*(2*k+1) > maxBandCells {
+			return nil
+		}
+		m := newBand(a, b, k)
+		if m.at(len(a), len(b)) <= k || k >= n {
+			return m
+		}
+		k = min(2*k, n)
+	}
+}
+
+// Appends the edits of a into b from the band to ops
+func backtrack(a []rune, b []rune, m *band, ops []Op) []Op {
+	start := len(ops)
+	i, j := len(a), len(b)
+	for i > 0 || j > 0 {
+		d := m.at(i, j)
+		switch {
+		case i > 0 && j > 0 && a[i-1] == b[j-1] && d == m.at(i-1, j-1):
+			ops = append(ops, Op{Match, string(a[i-1]), string(b[j-1])})
+			i, j = i-1, j-1
+		case i > 0 && j > 0 && d == m.at(i-1, j-1)+1:
+			ops = append(ops, Op{Substitute, string(a[i-1]), string(b[j-1])})
+			i, j = i-1, j-1
+		case i > 0 && d == m.at(i-1, j)+1:
+			ops = append(ops, Op{Delete, string(a[i-1]), ""})
+			i--
+		default:
+			ops = append(ops, Op{Insert, "", string(b[j-1])})
+			j--
+		}
+	}
+	for l, r := start, len(ops)-1; l < r; l, r = l+1, r-1 {
+		ops[l], ops[r] = ops[r], ops[l]
+	}
+	return ops
+}
+
+// Appends the edits of a into b to ops. Splits a in half and b where the
+// halves' distances sum to the least, then aligns each pair of halves
+func hirschberg(a []rune, b []rune, ops []Op) []Op {
+	if len(a) < 2 || (len(a)+1)*(2*max(len(a), len(b))+1) <= maxBandCells {
+		// The whole matrix fits in a band, which is linear for one rune
+		k := max(1, max(len(a), len(b)))
+		return backtrack(a, b, newBand(a, b, k), ops)
+	}
+	mid := len(a) / 2
+	left := lastRow(a[:mid], b, false)
+	right := lastRow(a[mid:], b, true)
+	split := 0
+	for j := range left {
+		if left[j]+right[len(b)-j] < left[split]+right[len(b)-split] {
+			split = j
+		}
+	}
+	ops = hirschberg(a[:mid], b[:split], ops)
+	return hirschberg(a[mid:], b[split:], ops)
+}
+
+// Returns the distances from a to each prefix of b, keeping only two rows.
+// If reversed, from reversed a to each reversed suffix of b
+func lastRow(a []rune, b []rune, reversed bool) []int {
+	at := func(s []rune, i int) rune {
+		if reversed {
+			return s[len(s)-1-i]
+		}
+		return s[i]
+	}
+	prev := make([]int, len(b)+1)
+	row := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 0; i < len(a); i++ {
+		row[0] = i + 1
+		for j := 1; j <= len(b); j++ {
+			sub := prev[j-1]
+			if at(a, i) != at(b, j-1) {
+				sub++
+			}
+			row[j] = min(sub, min(prev[j], row[j-1])+1)
+		}
+		prev, row = row, prev
+	}
+	return prev
+}
+
+// Text of a in place of the text of b, i.e. rn for m
+type Confusion struct {
+	A string
+	B string
+}
+
+// Returns the confusions of an alignment. Adjacent edits are merged into a
+// single confusion so that rn for m is one confusion, not two edits
+func Confusions(ops []Op) []Confusion {
+	var cs []Confusion
+	var run *Confusion
+	for _, op := range ops {
+		if op.Kind == Match {
+			run = nil
+			continue
+		}
+		if run == nil {
+			cs = append(cs, Confusion{})
+			run = &cs[len(cs)-1]
+		}
+		run.A += op.A
+		run.B += op.B
+	}
+	return cs
+}
@@ -31,7 +31,7 @@ func levenshtein(a []byte, b []byte) [][]int {
 }
 
 func Levenshtein(a []byte, b []byte) int {
-	return levenshtein(a, b)[len(a)][len(b)]
+	return levenshteinBytes(a, b)
 }
 
 // Character Error Rate
@@ -82,3 +82,148 @@ func printTable(a string, b string, dist [][]int) string {
 	}
 	return c.String()
 }
+
+func TestWER(t *testing.T) {
+	if d := TokenLevenshtein(Fields("the quick brown fox"), Fields("the quack brown")); d != 2 {
+		t.Fatalf("Expected token distance 2. Received: %v", d)
+	}
+	if wer := WER("the quick brown", "the quick brown", nil); wer != 0 {
+		t.Fatalf("Expected WER 0. Received: %v", wer)
+	}
+	if wer := WER("the quack brown fox", "the quick brown", nil); wer != 2.0/3 {
+		t.Fatalf("Expected WER 2/3. Received: %v", wer)
+	}
+	if wer := WER("word", "", nil); wer != 1 {
+		t.Fatalf("Expected WER 1 for empty reference. Received: %v", wer)
+	}
+	tokens := FieldsPunct("Hello, world!  (x)")
+	if strings.Join(tokens, "|") != "Hello|,|world|!|(|x|)" {
+		t.Fatalf("Unexpected punct tokens: %q", tokens)
+	}
+	if wer := WER("Hello world", "Hello, world", FieldsPunct); wer != 1.0/3 {
+		t.Fatalf("Expected WER 1/3 with punct tokens. Received: %v", wer)
+	}
+	if _, err := ParseTokenizer("chars"); err == nil {
+		t.Fatal("Expected error for unknown tokenizer")
+	}
+}
+
+func TestLevenshteinRunes(t *testing.T) {
+	f, err := os.Open(TEST_FILENAME)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		log.Fatalf("Error parsing %v: %v", TEST_FILENAME, err)
+	}
+	for _, record := range records {
+		exp, _ := strconv.Atoi(record[2])
+		a, b := []rune(record[0]), []rune(record[1])
+		if dist := LevenshteinRunes(a, b); dist != exp {
+			t.Fatalf("Runes: expected %v. Received: %v. Lev '%v' '%v'", exp, dist, record[0], record[1])
+		}
+		if dist := LevenshteinBanded(a, b); dist != exp {
+			t.Fatalf("Banded: expected %v. Received: %v. Lev '%v' '%v'", exp, dist, record[0], record[1])
+		}
+	}
+
+	// Multi-byte characters are a single edit
+	a, b := []byte("ſtreet — café"), []byte("street - cafe")
+	if dist, blen := Bytes.Distance(a, b); dist <= 3 || blen != len(b) {
+		t.Fatalf("Expected more than 3 byte edits. Received: %v", dist)
+	}
+	for _, algo := range []Algorithm{Runes, Banded} {
+		if dist, blen := algo.Distance(a, b); dist != 3 || blen != 13 {
+			t.Fatalf("Expected 3 rune edits of 13. Received: %v of %v", dist, blen)
+		}
+	}
+}
+
+func TestLevenshteinBandedLong(t *testing.T) {
+	a := []rune(strings.Repeat("the quick brown fox ", 200))
+	b := append([]rune("x"), a[:len(a)-7]...)
+	if exp, dist := LevenshteinRunes(a, b), LevenshteinBanded(a, b); exp != dist {
+		t.Fatalf("Expected %v. Received: %v", exp, dist)
+	}
+}
+
+func TestAlign(t *testing.T) {
+	f, err := os.Open(TEST_FILENAME)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		log.Fatalf("Error parsing %v: %v", TEST_FILENAME, err)
+	}
+	for _, record := range records {
+		exp, _ := strconv.Atoi(record[2])
+		ops := Align([]rune(record[0]), []rune(record[1]))
+		edits := 0
+		var a, b strings.Builder
+		for _, op := range ops {
+			if op.Kind != Match {
+				edits++
+			}
+			a.WriteString(op.A)
+			b.WriteString(op.B)
+		}
+		if edits != exp || a.String() != record[0] || b.String() != record[1] {
+			t.Fatalf("Expected %v edits. Received %v for '%v' '%v': %v", exp, edits, record[0], record[1], ops)
+		}
+	}
+
+	cs := Confusions(Align([]rune("modern ſtreet"), []rune("modem street")))
+	if len(cs) != 2 || cs[0] != (Confusion{"rn", "m"}) || cs[1] != (Confusion{"ſ", "s"}) {
+		t.Fatalf("Unexpected confusions: %q", cs)
+	}
+}
+
+func TestBandedDissimilar(t *testing.T) {
+	// The band stops growing once it covers the whole matrix
+	a := []rune(strings.Repeat("a", 300))
+	b := []rune(strings.Repeat("b", 250))
+	if dist := LevenshteinBanded(a, b); dist != 300 {
+		t.Fatalf("Expected 300. Received: %v", dist)
+	}
+	edits := 0
+	for _, op := range Align(a, b) {
+		if op.Kind != Match {
+			edits++
+		}
+	}
+	if edits != 300 {
+		t.Fatalf("Expected 300 edits. Received: %v", edits)
+	}
+}
+
+func TestAlignLinearSpace(t *testing.T) {
+	// Force Hirschberg's algorithm on all but the smallest texts
+	defer func(cells int) { maxBandCells = cells }(maxBandCells)
+	maxBandCells = 64
+	cases := [][2]string{
+		{"kitten sitting on the mat", "sitting kitten at the mall"},
+		{strings.Repeat("a", 300), strings.Repeat("b", 250)},
+		{"modern ſtreet and more", "modem street"},
+		{"", "abc"},
+		{"abcdefghij", "j"},
+	}
+	for _, c := range cases {
+		a, b := []rune(c[0]), []rune(c[1])
+		edits := 0
+		var ra, rb strings.Builder
+		for _, op := range Align(a, b) {
+			if op.Kind != Match {
+				edits++
+			}
+			ra.WriteString(op.A)
+			rb.WriteString(op.B)
+		}
+		if exp := LevenshteinRunes(a, b); edits != exp || ra.String() != c[0] || rb.String() != c[1] {
+			t.Fatalf("Expected %v edits. Received %v for %q %q", exp, edits, c[0], c[1])
+		}
+	}
+}
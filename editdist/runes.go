@@ -0,0 +1,144 @@
+package editdist
+
+import (
+	"fmt"
+)
+
+func max(a int, b int) int {
+	if a >= b {
+		return a
+	} else {
+		return b
+	}
+}
+
+// Levenshtein distance over symbols using two rows of memory. The bytes,
+// runes and tokens of the other distances are all symbols
+func levenshteinInts(a []int, b []int) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j // First row
+	}
+	for i := 1; i < len(a)+1; i++ {
+		curr[0] = i // First col
+		for j := 1; j < len(b)+1; j++ {
+			del := curr[j-1] + 1
+			ins := prev[j] + 1
+			sub := prev[j-1]
+			if a[i-1] != b[j-1] {
+				sub += 1
+			}
+			curr[j] = min(sub, min(del, ins))
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func bytesToInts(s []byte) []int {
+	is := make([]int, len(s))
+	for i, c := range s {
+		is[i] = int(c)
+	}
+	return is
+}
+
+func runesToInts(s []rune) []int {
+	is := make([]int, len(s))
+	for i, r := range s {
+		is[i] = int(r)
+	}
+	return is
+}
+
+// Levenshtein distance over bytes using two rows of memory
+func levenshteinBytes(a []byte, b []byte) int {
+	return levenshteinInts(bytesToInts(a), bytesToInts(b))
+}
+
+// Levenshtein distance over runes using two rows of memory. Multi-byte
+// characters count as a single edit
+func LevenshteinRunes(a []rune, b []rune) int {
+	return levenshteinInts(runesToInts(a), runesToInts(b))
+}
+
+// Levenshtein distance if it is at most k. Otherwise k+1. Only the cells
+// within k of the diagonal are computed
+func bandedRunes(a []rune, b []rune, k int) int {
+	inf := k + 1
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = min(j, inf) // First row
+	}
+	for i := 1; i < len(a)+1; i++ {
+		lo, hi := max(1, i-k), min(len(b), i+k)
+		curr[0] = min(i, inf) // First col
+		if lo > 1 {
+			curr[lo-1] = inf
+		}
+		for j := lo; j <= hi; j++ {
+			del := curr[j-1] + 1
+			ins := prev[j] + 1
+			sub := prev[j-1]
+			if a[i-1] != b[j-1] {
+				sub += 1
+			}
+			curr[j] = min(inf, min(sub, min(del, ins)))
+		}
+		if hi < len(b) {
+			curr[hi+1] = inf
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// Levenshtein distance over runes in O(d*n) time where d is the distance
+// (Ukkonen). The band around the diagonal doubles until it holds the result
+func LevenshteinBanded(a []rune, b []rune) int {
+	k := max(1, max(len(a)-len(b), len(b)-len(a)))
+	// A band as wide as the longer text covers the whole matrix
+	n := max(len(a), len(b))
+	for {
+		if dist := bandedRunes(a, b, k); dist <= k || k >= n {
+			return dist
+		}
+		k = min(2*k, n)
+	}
+}
+
+type Algorithm int
+
+const (
+	Bytes  Algorithm = iota // Two rows over bytes
+	Runes                   // Two rows over runes
+	Banded                  // Ukkonen's band over runes
+)
+
+func ParseAlgorithm(s string) (Algorithm, error) {
+	switch s {
+	case "bytes":
+		return Bytes, nil
+	case "runes":
+		return Runes, nil
+	case "banded":
+		return Banded, nil
+	default:
+		return 0, fmt.Errorf("Algorithm %v is not {bytes, runes, banded}", s)
+	}
+}
+
+// Returns the edit distance of a and b and the length of b, both in bytes
+// for Bytes and in runes otherwise. The length is the one CER expects
+func (algo Algorithm) Distance(a []byte, b []byte) (int, int) {
+	if algo == Bytes {
+		return Levenshtein(a, b), len(b)
+	}
+	ra, rb := []rune(string(a)), []rune(string(b))
+	if algo == Banded {
+		return LevenshteinBanded(ra, rb), len(rb)
+	}
+	return LevenshteinRunes(ra, rb), len(rb)
+}
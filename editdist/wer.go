@@ -0,0 +1,76 @@
+package editdist
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Splits text into the tokens compared by WER
+type Tokenizer func(text string) []string
+
+// Splits on whitespace
+func Fields(text string) []string {
+	return strings.Fields(text)
+}
+
+// Splits on whitespace and makes each punctuation mark its own token
+func FieldsPunct(text string) []string {
+	var tokens []string
+	for _, field := range strings.Fields(text) {
+		start := 0
+		for i, r := range field {
+			if unicode.IsPunct(r) {
+				if start < i {
+					tokens = append(tokens, field[start:i])
+				}
+				tokens = append(tokens, string(r))
+				start = i + len(string(r))
+			}
+		}
+		if start < len(field) {
+			tokens = append(tokens, field[start:])
+		}
+	}
+	return tokens
+}
+
+func ParseTokenizer(s string) (Tokenizer, error) {
+	switch s {
+	case "fields":
+		return Fields, nil
+	case "punct":
+		return FieldsPunct, nil
+	default:
+		return nil, fmt.Errorf("Tokenizer %v is not {fields, punct}", s)
+	}
+}
+
+// Levenshtein distance over token sequences. Tokens are numbered so that
+// equal tokens have equal numbers
+func TokenLevenshtein(a []string, b []string) int {
+	ids := make(map[string]int)
+	number := func(tokens []string) []int {
+		is := make([]int, len(tokens))
+		for i, t := range tokens {
+			id, ok := ids[t]
+			if !ok {
+				id = len(ids)
+				ids[t] = id
+			}
+			is[i] = id
+		}
+		return is
+	}
+	return levenshteinInts(number(a), number(b))
+}
+
+// Word Error Rate of a against the reference b. Uses Fields if tokenize
+// is nil
+func WER(a string, b string, tokenize Tokenizer) float64 {
+	if tokenize == nil {
+		tokenize = Fields
+	}
+	ta, tb := tokenize(a), tokenize(b)
+	return CER(TokenLevenshtein(ta, tb), len(tb))
+}
@@ -7,6 +7,8 @@ require (
 	github.com/aws/aws-sdk-go v1.30.21
 	github.com/jung-kurt/gofpdf v1.16.1
 	github.com/ughe/explorer v1.1.2
+	golang.org/x/text v0.3.2
 	google.golang.org/api v0.20.0
 	google.golang.org/genproto v0.0.0-20200331122359-1ee6d9798940
+	google.golang.org/grpc v1.28.0
 )
@@ -80,6 +80,11 @@ func (n *Node) Search(val [K]T) *Node {
 	return nil
 }
 
+// Returns the value stored in the node
+func (n *Node) Value() [K]T {
+	return n.val
+}
+
 // Return true if the current node's value is within the given bounds
 func (n *Node) inRegion(b bounds) bool {
 	for d := 0; d < K; d++ {
@@ -0,0 +1,121 @@
+// Package normalize rewrites text before error rates are scored so that
+// differences in layout, such as line breaks and hyphenation, are not
+// counted as OCR errors
+package normalize
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Collapses runs of whitespace, including line breaks, into single spaces
+func Whitespace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// Applies Unicode NFKC normalization, i.e. the ligature ﬁ becomes fi
+func NFKC(text string) string {
+	return norm.NFKC.String(text)
+}
+
+// Hyphen, soft hyphen or unicode hyphen at the end of a line within a word
+var lineEndHyphen = regexp.MustCompile(`(\pL)[-\x{00AD}\x{2010}][ \t]*\r?\n[ \t]*(\pL)`)
+
+// Joins words hyphenated across line ends
+func Dehyphenate(text string) string {
+	return lineEndHyphen.ReplaceAllString(text, "$1$2")
+}
+
+// Folds case, i.e. Straße becomes strasse
+func Fold(text string) string {
+	return cases.Fold().String(text)
+}
+
+// Removes punctuation
+func StripPunct(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+type step struct {
+	name string
+	f    func(string) string
+}
+
+// Steps in the order they are applied. Dehyphenation needs the line breaks
+// that whitespace collapsing removes, and stripping punctuation can leave
+// runs of whitespace
+var steps = []step{
+	{"nfkc", NFKC},
+	{"dehyphen", Dehyphenate},
+	{"case", Fold},
+	{"punct", StripPunct},
+	{"ws", Whitespace},
+}
+
+// The steps of "default"
+const Default = "nfkc,dehyphen,ws"
+
+// Normalizer applies a set of steps. The zero value leaves text unchanged
+type Normalizer struct {
+	steps []step
+}
+
+// Parses comma separated step names: nfkc, dehyphen, case, punct, ws, or
+// default, which adds its steps, i.e. default,case. The empty string and
+// none select no steps
+func Parse(spec string) (Normalizer, error) {
+	names := make(map[string]bool)
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "default" {
+			for _, d := range strings.Split(Default, ",") {
+				names[d] = true
+			}
+		} else if name != "" && name != "none" {
+			names[name] = true
+		}
+	}
+	var n Normalizer
+	for _, s := range steps {
+		if names[s.name] {
+			n.steps = append(n.steps, s)
+			delete(names, s.name)
+		}
+	}
+	if len(names) > 0 {
+		unknown := make([]string, 0, len(names))
+		for name := range names {
+			unknown = append(unknown, name)
+		}
+		sort.Strings(unknown)
+		return Normalizer{}, fmt.Errorf("Normalization %v is not {nfkc, dehyphen, case, punct, ws, default, none}", strings.Join(unknown, ","))
+	}
+	return n, nil
+}
+
+// Returns the normalized text
+func (n Normalizer) String(text string) string {
+	for _, s := range n.steps {
+		text = s.f(text)
+	}
+	return text
+}
+
+// Returns the normalized text
+func (n Normalizer) Bytes(text []byte) []byte {
+	if len(n.steps) == 0 {
+		return text
+	}
+	return []byte(n.String(string(text)))
+}
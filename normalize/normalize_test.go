@@ -0,0 +1,53 @@
+package normalize
+
+import (
+	"testing"
+)
+
+func TestSteps(t *testing.T) {
+	cases := []struct {
+		f        func(string) string
+		in, want string
+	}{
+		{Whitespace, "  the \t quick\n\nbrown  ", "the quick brown"},
+		{NFKC, "ﬁne ｆｕｌｌ", "fine full"},
+		{Dehyphenate, "exam-\nple and well-known", "example and well-known"},
+		{Dehyphenate, "soft­ \n hyphen", "softhyphen"},
+		{Fold, "Straße", "strasse"},
+		{StripPunct, "Hello, world! (x)", "Hello world x"},
+	}
+	for _, c := range cases {
+		if got := c.f(c.in); got != c.want {
+			t.Fatalf("Expected %q. Received: %q", c.want, got)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	n, err := Parse("ws,dehyphen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Dehyphenation runs before whitespace collapses the line breaks
+	if got := n.String("exam-\nple  text"); got != "example text" {
+		t.Fatalf("Expected steps in order. Received: %q", got)
+	}
+	n, _ = Parse("")
+	if got := n.String("As Is\n"); got != "As Is\n" {
+		t.Fatalf("Expected no change. Received: %q", got)
+	}
+	n, _ = Parse("default")
+	if got := n.String("Of-\nfice ﬁle"); got != "Office file" {
+		t.Fatalf("Expected default steps. Received: %q", got)
+	}
+	n, err = Parse("default,case")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := n.String("Of-\nfice ﬁle"); got != "office file" {
+		t.Fatalf("Expected default steps and case. Received: %q", got)
+	}
+	if _, err := Parse("ws,upper"); err == nil {
+		t.Fatal("Expected error for unknown step")
+	}
+}
@@ -0,0 +1,211 @@
+package ocr
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+)
+
+const altoNamespace = "http://www.loc.gov/standards/alto/ns-v4#"
+
+// ALTO v4 (www.loc.gov/standards/alto/) with only what Detection keeps.
+// Positions are in pixels
+type altoDoc struct {
+	XMLName     xml.Name        `xml:"alto"`
+	Xmlns       string          `xml:"xmlns,attr,omitempty"`
+	Description altoDescription `xml:"Description"`
+	Pages       []altoPage      `xml:"Layout>Page"`
+}
+
+type altoDescription struct {
+	Unit       string          `xml:"MeasurementUnit"`
+	Processing *altoProcessing `xml:"OCRProcessing,omitempty"`
+}
+
+type altoProcessing struct {
+	ID       string `xml:"ID,attr"`
+	Software string `xml:"ocrProcessingStep>processingSoftware>softwareName"`
+}
+
+type altoPage struct {
+	ID         string         `xml:"ID,attr"`
+	Number     int            `xml:"PHYSICAL_IMG_NR,attr"`
+	Width      float64        `xml:"WIDTH,attr,omitempty"`
+	Height     float64        `xml:"HEIGHT,attr,omitempty"`
+	PrintSpace altoPrintSpace `xml:"PrintSpace"`
+}
+
+type altoPrintSpace struct {
+	altoBox
+	Blocks []altoBlock `xml:"TextBlock"`
+}
+
+// Decodes the text blocks in document order, including those of (nested)
+// ComposedBlocks, which documents from other tools use to group blocks
+func (ps *altoPrintSpace) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	blocks, err := decodeAltoBlocks(d)
+	if err != nil {
+		return err
+	}
+	ps.Blocks = blocks
+	return nil
+}
+
+// Returns the TextBlocks up to the end of the current element, in order.
+// ComposedBlocks are flattened and other elements skipped
+func decodeAltoBlocks(d *xml.Decoder) ([]altoBlock, error) {
+	var blocks []altoBlock
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "TextBlock":
+				var b altoBlock
+				if err := d.DecodeElement(&b, &t); err != nil {
+					return nil, err
+				}
+				blocks = append(blocks, b)
+			case "ComposedBlock":
+				composed, err := decodeAltoBlocks(d)
+				if err != nil {
+					return nil, err
+				}
+				blocks = append(blocks, composed...)
+			default:
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+			}
+		case xml.EndElement:
+			return blocks, nil
+		}
+	}
+}
+
+type altoBox struct {
+	HPos   float64 `xml:"HPOS,attr"`
+	VPos   float64 `xml:"VPOS,attr"`
+	Width  float64 `xml:"WIDTH,attr"`
+	Height float64 `xml:"HEIGHT,attr"`
+}
+
+type altoBlock struct {
+	ID string `xml:"ID,attr"`
+	altoBox
+	Lang  string     `xml:"LANG,attr,omitempty"`
+	Lines []altoLine `xml:"TextLine"`
+}
+
+type altoLine struct {
+	ID string `xml:"ID,attr"`
+	altoBox
+	Strings []altoString `xml:"String"`
+}
+
+type altoString struct {
+	ID string `xml:"ID,attr"`
+	altoBox
+	Content string  `xml:"CONTENT,attr"`
+	WC      float64 `xml:"WC,attr,omitempty"` // Confidence in [0, 1]
+}
+
+func newAltoBox(bounds string) (altoBox, error) {
+	b, err := DecodeBounds(bounds)
+	if err != nil {
+		return altoBox{}, err
+	}
+	return altoBox{float64(b.X), float64(b.Y), float64(b.W), float64(b.H)}, nil
+}
+
+func (b altoBox) bounds() string {
+	round := func(f float64) int { return int(math.Round(f)) }
+	return encodeRawBounds(round(b.HPos), round(b.VPos), round(b.Width), round(b.Height))
+}
+
+// Returns the detection as ALTO v4 XML. Blocks are TextBlock, lines TextLine
+// and words String. Word confidences are WC
+func (d *Detection) ALTO() ([]byte, error) {
+	doc := altoDoc{Xmlns: altoNamespace, Description: altoDescription{Unit: "pixel"}}
+	if d.AlgoID != "" {
+		doc.Description.Processing = &altoProcessing{ID: "ocr_1", Software: d.AlgoID}
+	}
+	for pi, p := range d.Pages {
+		pb, err := p.bounds()
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %v", pi+1, err)
+		}
+		page := altoPage{ID: fmt.Sprintf("page_%d", pi+1), Number: pi + 1, Width: float64(pb.W), Height: float64(pb.H)}
+		page.PrintSpace.altoBox = altoBox{0, 0, float64(pb.W), float64(pb.H)}
+		nl, nw := 0, 0
+		for bi, b := range p.Blocks {
+			box, err := newAltoBox(b.Bounds)
+			if err != nil {
+				return nil, fmt.Errorf("page %d block %d: %v", pi+1, bi+1, err)
+			}
+			block := altoBlock{ID: fmt.Sprintf("block_%d_%d", pi+1, bi+1), altoBox: box, Lang: p.Lang}
+			for _, l := range b.Lines {
+				nl++
+				box, err := newAltoBox(l.Bounds)
+				if err != nil {
+					return nil, fmt.Errorf("page %d line %d: %v", pi+1, nl, err)
+				}
+				line := altoLine{ID: fmt.Sprintf("line_%d_%d", pi+1, nl), altoBox: box}
+				for _, w := range l.Words {
+					nw++
+					box, err := newAltoBox(w.Bounds)
+					if err != nil {
+						return nil, fmt.Errorf("page %d word %d: %v", pi+1, nw, err)
+					}
+					line.Strings = append(line.Strings, altoString{fmt.Sprintf("word_%d_%d", pi+1, nw), box, w.Text, w.Conf})
+				}
+				block.Lines = append(block.Lines, line)
+			}
+			page.PrintSpace.Blocks = append(page.PrintSpace.Blocks, block)
+		}
+		doc.Pages = append(doc.Pages, page)
+	}
+	encoded, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(encoded, '\n')...), nil
+}
+
+// Decodes ALTO XML in pixels, such as ground truth, into a Detection
+func DecodeALTO(data []byte) (*Detection, error) {
+	var doc altoDoc
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if unit := doc.Description.Unit; unit != "" && unit != "pixel" {
+		return nil, fmt.Errorf("ALTO MeasurementUnit %s is not pixel", unit)
+	}
+	algoID := "alto"
+	if p := doc.Description.Processing; p != nil && p.Software != "" {
+		algoID = sanitizeString("alto-" + p.Software)
+	}
+	d := &Detection{AlgoID: algoID}
+	for _, ap := range doc.Pages {
+		page := Page{Width: int(math.Round(ap.Width)), Height: int(math.Round(ap.Height))}
+		for _, ab := range ap.PrintSpace.Blocks {
+			if page.Lang == "" {
+				page.Lang = ab.Lang
+			}
+			block := Block{Bounds: ab.bounds()}
+			for _, al := range ab.Lines {
+				line := Line{Bounds: al.bounds()}
+				for _, as := range al.Strings {
+					line.Words = append(line.Words, Word{Bounds: as.bounds(), Text: as.Content, Conf: as.WC})
+				}
+				block.Lines = append(block.Lines, line)
+			}
+			page.Blocks = append(page.Blocks, block)
+		}
+		d.Pages = append(d.Pages, page)
+	}
+	return d, nil
+}
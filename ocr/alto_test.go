@@ -0,0 +1,77 @@
+package ocr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestALTORoundTrip(t *testing.T) {
+	d := testDetection()
+	doc, err := d.ALTO()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<alto xmlns="http://www.loc.gov/standards/alto/ns-v4#">`,
+		`<MeasurementUnit>pixel</MeasurementUnit>`,
+		`<String ID="word_1_1" HPOS="10" VPOS="10" WIDTH="40" HEIGHT="20" CONTENT="Hello" WC="0.87"></String>`,
+		`CONTENT="&lt;world&gt;"`,
+	} {
+		assert(t, strings.Contains(string(doc), want), "alto contains "+want)
+	}
+	back, err := DecodeXML(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, back.AlgoID == "alto-azu-test", "alto algo id: "+back.AlgoID)
+	if !reflect.DeepEqual(back.Pages, d.Pages) {
+		t.Fatalf("Expected %+v. Received: %+v", d.Pages, back.Pages)
+	}
+}
+
+func TestDecodeALTO(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<alto xmlns="http://www.loc.gov/standards/alto/ns-v4#">
+  <Description><MeasurementUnit>pixel</MeasurementUnit></Description>
+  <Layout><Page ID="p1" PHYSICAL_IMG_NR="1" WIDTH="300" HEIGHT="200"><PrintSpace>
+    <ComposedBlock ID="c1"><TextBlock ID="b1" HPOS="1.4" VPOS="2" WIDTH="50.6" HEIGHT="10">
+      <TextLine ID="l1" HPOS="1" VPOS="2" WIDTH="50" HEIGHT="10">
+        <String CONTENT="Ground" HPOS="1" VPOS="2" WIDTH="20" HEIGHT="10"/><SP/>
+        <String CONTENT="truth" HPOS="25" VPOS="2" WIDTH="26" HEIGHT="10"/>
+      </TextLine>
+    </TextBlock></ComposedBlock>
+  </PrintSpace></Page></Layout>
+</alto>`
+	d, err := DecodeXML([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, d.AlgoID == "alto" && d.Pages[0].Width == 300, "alto page")
+	assert(t, d.Pages[0].Blocks[0].Bounds == "1,2,51,10", "alto rounds positions")
+	assert(t, d.Plaintext() == "Ground truth", "alto text: "+d.Plaintext())
+
+	mm := strings.Replace(doc, ">pixel<", ">mm10<", 1)
+	_, err = DecodeXML([]byte(mm))
+	assert(t, err != nil, "alto mm10 unsupported")
+	_, err = DecodeXML([]byte("<html></html>"))
+	assert(t, err != nil, "unknown xml root")
+}
+
+func TestDecodeALTOOrder(t *testing.T) {
+	block := func(text string) string {
+		return `<TextBlock HPOS="0" VPOS="0" WIDTH="10" HEIGHT="10"><TextLine HPOS="0" VPOS="0" WIDTH="10" HEIGHT="10">` +
+			`<String CONTENT="` + text + `" HPOS="0" VPOS="0" WIDTH="10" HEIGHT="10"/></TextLine></TextBlock>`
+	}
+	// Composed blocks interleaved with text blocks keep document order
+	doc := `<alto><Layout><Page><PrintSpace HPOS="0" VPOS="0" WIDTH="100" HEIGHT="100">` +
+		block("one") +
+		`<ComposedBlock>` + block("two") + `<Illustration/><ComposedBlock>` + block("three") + `</ComposedBlock></ComposedBlock>` +
+		block("four") +
+		`</PrintSpace></Page></Layout></alto>`
+	d, err := DecodeALTO([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, d.Plaintext() == "one\ntwo\nthree\nfour", "alto order: "+d.Plaintext())
+}
@@ -1,8 +1,12 @@
 package ocr
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/color"
+	"net/http"
 	"path"
 	"strings"
 	"time"
@@ -14,12 +18,35 @@ import (
 
 type AWSClient struct {
 	CredentialsPath string
+	Endpoint        string       // Optional. Overrides the Textract endpoint
+	HTTPClient      *http.Client // Optional. Defaults to the SDK's client
+	Retry           RetryPolicy  // Optional. Defaults to DefaultRetryPolicy
+}
+
+func init() {
+	Register(Provider{
+		Name:    "aws",
+		Key:     "aws",
+		Service: "AWS",
+		AlgoID:  "aws",
+		Usage: "Run AWS Textract OCR. Key files: credentials config\n" +
+			"More info: https://docs.aws.amazon.com/textract/latest/dg/setup-awscli-sdk.html",
+		New:   func(keys string) Client { return AWSClient{CredentialsPath: keys} },
+		Color: color.RGBA{255, 165, 0, 255}, // Orange
+		// https://aws.amazon.com/textract/pricing/ (Detect Document Text API)
+		Pricing: Pricing{{1000000, 0.0015}, {0, 0.0006}},
+	})
 }
 
 // Method required by ocr.Client
 // Returns AWS document text detection Result
 // Reference: https://docs.aws.amazon.com/textract/
 func (c AWSClient) Run(image []byte) (*Result, error) {
+	return c.RunContext(context.Background(), image)
+}
+
+// Method required by ocr.Client
+func (c AWSClient) RunContext(ctx context.Context, image []byte) (*Result, error) {
 	const (
 		service    = "AWS"
 		keyName    = "credentials"
@@ -30,10 +57,13 @@ func (c AWSClient) Run(image []byte) (*Result, error) {
 	credentialsFile := path.Join(c.CredentialsPath, keyName)
 	configFile := path.Join(c.CredentialsPath, configName)
 
-	three := new(int)
-	*three = 3
+	// Retries are made by c.Retry so that they are counted
 	config := aws.Config{
-		MaxRetries: three,
+		MaxRetries: aws.Int(0),
+		HTTPClient: c.HTTPClient,
+	}
+	if c.Endpoint != "" {
+		config.Endpoint = aws.String(c.Endpoint)
 	}
 	s, err := session.NewSessionWithOptions(
 		session.Options{
@@ -53,11 +83,21 @@ func (c AWSClient) Run(image []byte) (*Result, error) {
 		Document: &doc,
 	}
 
-	start := time.Now()
-	result, err := client.DetectDocumentText(&ddti)
-	milli := int64(time.Since(start) / time.Millisecond)
+	// Only the duration of the successful attempt is reported
+	var start time.Time
+	var milli int64
+	var result *textract.DetectDocumentTextOutput
+	attempts, err := c.Retry.Do(ctx, func() error {
+		start = time.Now()
+		result, err = client.DetectDocumentTextWithContext(ctx, &ddti)
+		milli = int64(time.Since(start) / time.Millisecond)
+		if err != nil {
+			return fmt.Errorf("%s: OCR request failed - %w", service, err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("%s: OCR request failed - %v", service, err)
+		return nil, err
 	}
 
 	version := *result.DetectDocumentTextModelVersion
@@ -80,9 +120,16 @@ func (c AWSClient) Run(image []byte) (*Result, error) {
 		Duration: milli,
 		Date:     date,
 		Raw:      encoded,
+		Attempts: attempts,
 	}, err
 }
 
+// Method required by ocr.Recordable
+func (c AWSClient) WithRecorder(r *Recorder) Client {
+	c.HTTPClient = &http.Client{Transport: r}
+	return c
+}
+
 func geometryToBox(g *textract.Geometry, wi, hi int) string {
 	b := g.BoundingBox
 	w, h := float64(wi), float64(hi)
@@ -90,6 +137,27 @@ func geometryToBox(g *textract.Geometry, wi, hi int) string {
 		int(*b.Width*w+.5), int(*b.Height*h+.5))
 }
 
+// Textract confidences are percentages
+func percentToConf(p *float64) float64 {
+	if p == nil {
+		return 0
+	}
+	return *p / 100
+}
+
+// Returns the polygon of the geometry in pixels or "" if there is none
+func geometryToPoly(g *textract.Geometry, wi, hi int) string {
+	if len(g.Polygon) < 3 {
+		return ""
+	}
+	w, h := float64(wi), float64(hi)
+	ps := make([]image.Point, 0, len(g.Polygon))
+	for _, p := range g.Polygon {
+		ps = append(ps, image.Point{int(*p.X*w + .5), int(*p.Y*h + .5)})
+	}
+	return encodePoly(ps)
+}
+
 func relsToIds(rels []*textract.Relationship) ([]*string, error) {
 	for _, rel := range rels {
 		// Invariant: len(r.Relationships) <= 2 because Type is {CHILD, VALUE}
@@ -128,13 +196,16 @@ func (_ AWSClient) ResultToDetection(result *Result, width, height int) (*Detect
 		}
 	}
 
-	blocks := make([]Block, 0, len(mpages))
+	// Each page has a single block with the lines of the page
+	pages := make([]Page, 0, len(mpages))
 	for _, r := range mpages {
+		page := Page{Width: width, Height: height, Blocks: make([]Block, 0, 1)}
 		ids, err := relsToIds(r.Relationships)
 		if err != nil {
 			return nil, err
 		}
 		if ids == nil {
+			pages = append(pages, page)
 			continue
 		}
 		lines := make([]Line, 0, len(ids))
@@ -156,14 +227,29 @@ func (_ AWSClient) ResultToDetection(result *Result, width, height int) (*Detect
 				if !ok {
 					return nil, fmt.Errorf("Word %v not found", *id)
 				}
-				words = append(words, Word{geometryToBox(w.Geometry, width, height), *w.Text})
+				words = append(words, Word{
+					Bounds: geometryToBox(w.Geometry, width, height),
+					Text:   *w.Text,
+					Conf:   percentToConf(w.Confidence),
+					Poly:   geometryToPoly(w.Geometry, width, height),
+				})
 			}
-			lines = append(lines, Line{geometryToBox(l.Geometry, width, height), words})
+			lines = append(lines, Line{
+				Bounds: geometryToBox(l.Geometry, width, height),
+				Words:  words,
+				Conf:   percentToConf(l.Confidence),
+				Poly:   geometryToPoly(l.Geometry, width, height),
+			})
 		}
-		blocks = append(blocks, Block{geometryToBox(r.Geometry, width, height), lines})
+		page.Blocks = append(page.Blocks, Block{
+			Bounds: geometryToBox(r.Geometry, width, height),
+			Lines:  lines,
+			Poly:   geometryToPoly(r.Geometry, width, height),
+		})
+		pages = append(pages, page)
 	}
 
 	algoID := sanitizeString(result.Service[:3] + "-" + result.Version)
 	millis := uint32(result.Duration)
-	return &Detection{algoID, result.Date, millis, blocks}, nil
+	return &Detection{algoID, result.Date, millis, pages}, nil
 }
@@ -2,9 +2,12 @@ package ocr
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"image/color"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"path"
 	"strings"
@@ -21,6 +24,7 @@ type azureVisionResponse struct {
 	StatusMsg   string        `json:"message,omitempty"`
 	Language    string        `json:"language"`
 	Orientation string        `json:"orientation"`
+	TextAngle   float64       `json:"textAngle"` // Radians
 	Regions     []azureRegion `json:"regions"`
 }
 
@@ -41,6 +45,26 @@ type azureWord struct {
 
 type AzureClient struct {
 	CredentialsPath string
+	HTTPClient      *http.Client // Optional. Defaults to a client with httpTimeout
+	Retry           RetryPolicy  // Optional. Defaults to DefaultRetryPolicy
+}
+
+const httpTimeout = time.Second * 15
+
+func init() {
+	Register(Provider{
+		Name:    "azure",
+		Key:     "azu",
+		Service: "Azure",
+		AlgoID:  "azu",
+		Usage: "Run Azure CognitiveServices OCR. Key file: azure.json\n" +
+			"More info: https://docs.microsoft.com/azure/cognitive-services/cognitive-services-apis-create-account\n" +
+			"Note: Create a json file with 'subscription_key' and 'endpoint' items",
+		New:   func(keys string) Client { return AzureClient{CredentialsPath: keys} },
+		Color: color.RGBA{0, 0, 255, 255}, // Blue
+		// https://azure.microsoft.com/pricing/details/cognitive-services/computer-vision/
+		Pricing: Pricing{{1000000, 0.0015}, {10000000, 0.001}, {100000000, 0.00065}, {0, 0.0006}},
+	})
 }
 
 func loadCredentials(path string) (*azureClientCredentials, error) {
@@ -63,11 +87,15 @@ func loadCredentials(path string) (*azureClientCredentials, error) {
 // Returns Azure document text detection Result
 // Example: https://docs.microsoft.com/en-us/azure/cognitive-services/computer-vision/quickstarts/go-print-text
 func (c AzureClient) Run(image []byte) (*Result, error) {
+	return c.RunContext(context.Background(), image)
+}
+
+// Method required by ocr.Client
+func (c AzureClient) RunContext(ctx context.Context, image []byte) (*Result, error) {
 	const (
-		service     = "Azure"
-		keyName     = "azure.json"
-		uriVersion  = "vision/v3.1/ocr"
-		httpTimeout = time.Second * 15
+		service    = "Azure"
+		keyName    = "azure.json"
+		uriVersion = "vision/v3.1/ocr"
 	)
 
 	credentialsPath := path.Join(c.CredentialsPath, keyName)
@@ -80,24 +108,38 @@ func (c AzureClient) Run(image []byte) (*Result, error) {
 	params := "?language=unk&detectOrientation=false"
 	url := base + params
 
-	client := &http.Client{Timeout: httpTimeout}
-	req, err := http.NewRequest("POST", url, bytes.NewReader(image))
-	if err != nil {
-		return nil, fmt.Errorf("%s: configuration error: %v", service, err)
+	client := c.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: httpTimeout}
 	}
-	req.Header.Add("Content-Type", "application/octet-stream")
-	req.Header.Add("Ocp-Apim-Subscription-Key", credentials.Key)
-
-	start := time.Now()
-	response, err := client.Do(req)
-	milli := int64(time.Since(start) / time.Millisecond)
+	// Only the duration of the successful attempt is reported
+	var start time.Time
+	var milli int64
+	var response *http.Response
+	attempts, err := c.Retry.Do(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(image))
+		if err != nil {
+			return fmt.Errorf("%s: configuration error: %v", service, err)
+		}
+		req.Header.Add("Content-Type", "application/octet-stream")
+		req.Header.Add("Ocp-Apim-Subscription-Key", credentials.Key)
+
+		start = time.Now()
+		response, err = client.Do(req)
+		milli = int64(time.Since(start) / time.Millisecond)
+		if err != nil {
+			return fmt.Errorf("%s: OCR request failed - %w", service, err)
+		}
+		if response.StatusCode != 200 {
+			response.Body.Close()
+			return newStatusError(service, response, 200)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("%s: OCR request failed - %v", service, err)
+		return nil, err
 	}
 	defer response.Body.Close()
-	if response.StatusCode != 200 {
-		return nil, fmt.Errorf("%s: received status code %v (expected 200)", service, response.StatusCode)
-	}
 
 	responseJson, err := ioutil.ReadAll(response.Body)
 	if err != nil {
@@ -137,10 +179,17 @@ func (c AzureClient) Run(image []byte) (*Result, error) {
 		Duration: milli,
 		Date:     date,
 		Raw:      encoded,
+		Attempts: attempts,
 	}, err
 }
 
-func (_ AzureClient) ResultToDetection(result *Result, _, _ int) (*Detection, error) {
+// Method required by ocr.Recordable
+func (c AzureClient) WithRecorder(r *Recorder) Client {
+	c.HTTPClient = &http.Client{Timeout: httpTimeout, Transport: r}
+	return c
+}
+
+func (_ AzureClient) ResultToDetection(result *Result, width, height int) (*Detection, error) {
 	var response azureVisionResponse
 	err := json.Unmarshal(result.Raw, &response)
 	if err != nil {
@@ -153,13 +202,20 @@ func (_ AzureClient) ResultToDetection(result *Result, _, _ int) (*Detection, er
 		for _, l := range r.Lines {
 			words := make([]Word, 0, len(l.Words))
 			for _, w := range l.Words {
-				words = append(words, Word{w.Bounds, w.Text})
+				words = append(words, Word{Bounds: w.Bounds, Text: w.Text}) // No confidence in OCR API
 			}
-			lines = append(lines, Line{l.Bounds, words})
+			lines = append(lines, Line{Bounds: l.Bounds, Words: words})
 		}
-		blocks = append(blocks, Block{r.Bounds, lines})
+		blocks = append(blocks, Block{Bounds: r.Bounds, Lines: lines})
+	}
+	page := Page{
+		Width:  width,
+		Height: height,
+		Angle:  response.TextAngle * 180 / math.Pi,
+		Lang:   response.Language,
+		Blocks: blocks,
 	}
 	algoID := sanitizeString(result.Service[:3] + "-" + result.Version)
 	millis := uint32(result.Duration)
-	return &Detection{algoID, result.Date, millis, blocks}, nil
+	return &Detection{algoID, result.Date, millis, []Page{page}}, nil
 }
@@ -2,9 +2,13 @@ package ocr
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/color"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"path"
 	"strings"
@@ -60,16 +64,37 @@ type azureReadWord struct {
 
 type AzureReadClient struct {
 	CredentialsPath string
+	HTTPClient      *http.Client  // Optional. Defaults to a client with httpTimeout
+	PollInterval    time.Duration // Optional. Time between result queries. Defaults to 1s
+	Retry           RetryPolicy   // Optional. Defaults to DefaultRetryPolicy
+}
+
+func init() {
+	Register(Provider{
+		Name:    "azureR",
+		Key:     "azuR",
+		Service: "AzureRead",
+		AlgoID:  "AzureRead",
+		Usage:   "Run Azure CognitiveServices Read API. Key file: azure.json",
+		New:     func(keys string) Client { return AzureReadClient{CredentialsPath: keys} },
+		Color:   color.RGBA{0, 0, 255, 255}, // Blue
+		// https://azure.microsoft.com/pricing/details/cognitive-services/computer-vision/
+		Pricing: Pricing{{1000000, 0.0015}, {0, 0.0006}},
+	})
 }
 
 // Method required by ocr.Client
 // Returns Azure READ API document text detection Result
 func (c AzureReadClient) Run(image []byte) (*Result, error) {
+	return c.RunContext(context.Background(), image)
+}
+
+// Method required by ocr.Client
+func (c AzureReadClient) RunContext(ctx context.Context, image []byte) (*Result, error) {
 	const (
-		service     = "AzureRead"
-		keyName     = "azure.json"
-		uriVersion  = "vision/v3.1/read/analyze" // NOTE: May be different from version Azure returns in JSON
-		httpTimeout = time.Second * 15
+		service    = "AzureRead"
+		keyName    = "azure.json"
+		uriVersion = "vision/v3.1/read/analyze" // NOTE: May be different from version Azure returns in JSON
 	)
 
 	credentialsPath := path.Join(c.CredentialsPath, keyName)
@@ -82,47 +107,87 @@ func (c AzureReadClient) Run(image []byte) (*Result, error) {
 	// params := "?readingOrder=natural" // Applies only in v3.2-preview.* (not used currently)
 	url := base
 
-	client := &http.Client{Timeout: httpTimeout}
-	req, err := http.NewRequest("POST", url, bytes.NewReader(image))
-	if err != nil {
-		return nil, fmt.Errorf("%s: configuration error: %v", service, err)
+	client := c.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: httpTimeout}
 	}
-	req.Header.Add("Content-Type", "application/octet-stream")
-	req.Header.Add("Ocp-Apim-Subscription-Key", credentials.Key)
+	// Only the duration of the successful attempt is reported
+	var start time.Time
+	var response *http.Response
+	attempts, err := c.Retry.Do(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(image))
+		if err != nil {
+			return fmt.Errorf("%s: configuration error: %v", service, err)
+		}
+		req.Header.Add("Content-Type", "application/octet-stream")
+		req.Header.Add("Ocp-Apim-Subscription-Key", credentials.Key)
 
-	start := time.Now()
-	response, err := client.Do(req)
+		start = time.Now()
+		response, err = client.Do(req)
+		if err != nil {
+			return fmt.Errorf("%s: OCR request failed - %w", service, err)
+		}
+		if response.StatusCode != 202 {
+			response.Body.Close()
+			return newStatusError(service, response, 202)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("%s: OCR request failed - %v", service, err)
+		return nil, err
 	}
 	defer response.Body.Close()
-	if response.StatusCode != 202 {
-		return nil, fmt.Errorf("%s: received status code %v (expected 202)", service, response.StatusCode)
-	}
 	oploc := response.Header.Get("Operation-Location")
 	if oploc == "" {
 		return nil, fmt.Errorf("%s: empty Operation-Location (no results URL given)", service)
 	}
 
-	req2, err := http.NewRequest("GET", oploc, nil)
+	req2, err := http.NewRequestWithContext(ctx, "GET", oploc, nil)
 	if err != nil {
 		return nil, fmt.Errorf("%s: configuration error for result url: %s (%v)", service, oploc, err)
 	}
 	req2.Header.Add("Ocp-Apim-Subscription-Key", credentials.Key)
 
-	// Query results location every second until results are ready
+	// Query results location every second until results are ready. Without a
+	// deadline, give up after MAX_RESULT_TIMEOUTS queries
+	interval := c.PollInterval
+	if interval == 0 {
+		interval = 1 * time.Second
+	}
 	var milli int64
 	MAX_RESULT_TIMEOUTS := 15
+	if _, ok := ctx.Deadline(); ok {
+		MAX_RESULT_TIMEOUTS = math.MaxInt32
+	}
 	var result azureReadResponse
+	wait := interval
 	for i := 0; i < MAX_RESULT_TIMEOUTS; i++ {
-		time.Sleep(1 * time.Second)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%s: stopped waiting for a result. Last status was: %s for url: %s (%v)", service, result.Status, oploc, ctx.Err())
+		case <-time.After(wait):
+		}
+		wait = interval
 		response, err = client.Do(req2)
 		milli = int64(time.Since(start) / time.Millisecond)
 		if err != nil {
-			return nil, fmt.Errorf("%s: OCR (result) request failed - %v", service, err)
+			return nil, fmt.Errorf("%s: OCR (result) request failed - %w", service, err)
+		}
+		if response.StatusCode != 200 {
+			response.Body.Close()
+			serr := newStatusError(service, response, 200)
+			if !retryableStatus(serr.Code) {
+				return nil, serr
+			}
+			// Throttled while polling. Keep polling instead of resubmitting
+			if serr.RetryAfter > wait {
+				wait = serr.RetryAfter
+			}
+			continue
 		}
 
 		responseJson, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
 		if err != nil {
 			return nil, fmt.Errorf("%s: cannot read http response: %v", service, err)
 		}
@@ -181,30 +246,32 @@ func (c AzureReadClient) Run(image []byte) (*Result, error) {
 		Duration: milli,
 		Date:     date,
 		Raw:      encoded,
+		Attempts: attempts,
 	}, err
 }
 
+// Method required by ocr.Recordable
+func (c AzureReadClient) WithRecorder(r *Recorder) Client {
+	c.HTTPClient = &http.Client{Timeout: httpTimeout, Transport: r}
+	return c
+}
+
+func boundsToPoints(bb [8]int) []image.Point {
+	ps := make([]image.Point, 0, 4)
+	for i := 0; i < 4; i++ {
+		ps = append(ps, image.Point{bb[2*i], bb[2*i+1]})
+	}
+	return ps
+}
+
 // Convert four (X,Y) vertices into single (X,Y) with (W,H)
 // Similar to GCP's polyToBox function
 func boundsToBox(bb [8]int) string {
-	minx, miny := bb[0], bb[1]
-	maxx, maxy := minx, miny
-	for i := 1; i < 4; i++ {
-		x, y := bb[2*i], bb[2*i+1]
-		if x < minx {
-			minx = x
-		}
-		if y < miny {
-			miny = y
-		}
-		if x > maxx {
-			maxx = x
-		}
-		if y > maxy {
-			maxy = y
-		}
-	}
-	return encodeRawBounds(int(minx), int(miny), int(maxx-minx), int(maxy-miny))
+	return pointsToBounds(boundsToPoints(bb))
+}
+
+func boundsToPoly(bb [8]int) string {
+	return encodePoly(boundsToPoints(bb))
 }
 
 func (_ AzureReadClient) ResultToDetection(result *Result, _, _ int) (*Detection, error) {
@@ -214,19 +281,26 @@ func (_ AzureReadClient) ResultToDetection(result *Result, _, _ int) (*Detection
 		return nil, err
 	}
 
-	blocks := make([]Block, 0, len(response.AnalyzeResult.ReadResults))
+	// Each page has a single block with the lines of the page
+	pages := make([]Page, 0, len(response.AnalyzeResult.ReadResults))
 	for _, r := range response.AnalyzeResult.ReadResults {
 		lines := make([]Line, 0, len(r.Lines))
 		for _, l := range r.Lines {
 			words := make([]Word, 0, len(l.Words))
 			for _, w := range l.Words {
-				words = append(words, Word{boundsToBox(w.Bounds), w.Text})
+				words = append(words, Word{Bounds: boundsToBox(w.Bounds), Text: w.Text, Conf: w.Conf, Poly: boundsToPoly(w.Bounds)})
 			}
-			lines = append(lines, Line{boundsToBox(l.Bounds), words})
+			lines = append(lines, Line{Bounds: boundsToBox(l.Bounds), Words: words, Conf: meanConf(words), Poly: boundsToPoly(l.Bounds)})
 		}
-		blocks = append(blocks, Block{encodeRawBounds(0, 0, r.Width, r.Height), lines})
+		pages = append(pages, Page{
+			Width:  r.Width,
+			Height: r.Height,
+			Angle:  r.Angle,
+			Lang:   r.Lang,
+			Blocks: []Block{Block{Bounds: encodeRawBounds(0, 0, r.Width, r.Height), Lines: lines}},
+		})
 	}
 	algoID := sanitizeString(result.Service + "-" + result.Version)
 	millis := uint32(result.Duration)
-	return &Detection{algoID, result.Date, millis, blocks}, nil
+	return &Detection{algoID, result.Date, millis, pages}, nil
 }
@@ -2,6 +2,7 @@ package ocr
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"image"
@@ -18,25 +19,67 @@ import (
 )
 
 type Detection struct {
-	AlgoID string  `json:"algo"`
-	Date   string  `json:"date"`
-	Millis uint32  `json:"millis"`
+	AlgoID string `json:"algo"`
+	Date   string `json:"date"`
+	Millis uint32 `json:"millis"`
+	Pages  []Page `json:"pages"`
+}
+
+type Page struct {
+	Width  int     `json:"width,omitempty"`
+	Height int     `json:"height,omitempty"`
+	Angle  float64 `json:"angle,omitempty"` // Clockwise text rotation in degrees
+	Lang   string  `json:"lang,omitempty"`
 	Blocks []Block `json:"blocks"`
 }
 
+// Decodes detections from before pages were added, which have their
+// blocks at the top level, as a single page
+func (d *Detection) UnmarshalJSON(data []byte) error {
+	type detection Detection // Without methods to avoid recursion
+	var v struct {
+		detection
+		Blocks []Block `json:"blocks"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*d = Detection(v.detection)
+	if d.Pages == nil && v.Blocks != nil {
+		d.Pages = []Page{Page{Blocks: v.Blocks}}
+	}
+	return nil
+}
+
+// Returns the only page, or an empty page if there are none. Errors if
+// there are several, such as when drawing on the image of a single page
+func (d *Detection) onlyPage() (*Page, error) {
+	if len(d.Pages) == 0 {
+		return &Page{}, nil
+	} else if len(d.Pages) > 1 {
+		return nil, fmt.Errorf("Expected a single page instead of: %d pages", len(d.Pages))
+	}
+	return &d.Pages[0], nil
+}
+
 type Block struct {
-	Bounds string `json:"xywh"`
+	Bounds string `json:"xywh"` // Axis-aligned
 	Lines  []Line `json:"lines"`
+	Poly   string `json:"poly,omitempty"` // Optional, possibly rotated, polygon from the provider: x0,y0,x1,y1,...
 }
 
 type Line struct {
-	Bounds string `json:"xywh"`
-	Words  []Word `json:"words"`
+	Bounds string  `json:"xywh"` // Axis-aligned
+	Words  []Word  `json:"words"`
+	Conf   float64 `json:"conf,omitempty"` // Confidence in [0, 1]. 0 if unknown
+	Poly   string  `json:"poly,omitempty"` // Optional, possibly rotated, polygon like Block.Poly
 }
 
 type Word struct {
-	Bounds string `json:"xywh"`
-	Text   string `json:"text"`
+	Bounds string  `json:"xywh"` // Axis-aligned
+	Text   string  `json:"text"`
+	Conf   float64 `json:"conf,omitempty"` // Confidence in [0, 1]. 0 if unknown
+	Poly   string  `json:"poly,omitempty"` // Optional, possibly rotated, polygon like Block.Poly
 }
 
 type Bounds struct {
@@ -52,9 +95,9 @@ type bWord struct {
 	t string
 }
 
-func (d *Detection) Plaintext() string {
+func (p *Page) Plaintext() string {
 	var blocks []string
-	for _, b := range d.Blocks {
+	for _, b := range p.Blocks {
 		var lines []string
 		for _, l := range b.Lines {
 			var words []string
@@ -69,48 +112,120 @@ func (d *Detection) Plaintext() string {
 	return fullText
 }
 
+// Returns the text of each page. Pages are separated by form feeds
+func (d *Detection) Plaintext() string {
+	pages := make([]string, 0, len(d.Pages))
+	for i := range d.Pages {
+		pages = append(pages, d.Pages[i].Plaintext())
+	}
+	return strings.Join(pages, "\f")
+}
+
 func (d *Detection) CountBLW() (int, int, int) {
 	nb, nl, nw := 0, 0, 0
-	for _, b := range d.Blocks {
-		nb++
-		for _, l := range b.Lines {
-			nl++
-			nw += len(l.Words)
+	for _, p := range d.Pages {
+		for _, b := range p.Blocks {
+			nb++
+			for _, l := range b.Lines {
+				nl++
+				nw += len(l.Words)
+			}
 		}
 	}
 	return nb, nl, nw
 }
 
+// Returns the mean and minimum confidence of the words with a known
+// confidence along with their count. Returns zeros if none are known
+func (d *Detection) Confidence() (float64, float64, int) {
+	sum, lo, n := 0.0, 1.0, 0
+	for _, p := range d.Pages {
+		for _, b := range p.Blocks {
+			for _, l := range b.Lines {
+				for _, w := range l.Words {
+					if w.Conf > 0 {
+						sum += w.Conf
+						lo = math.Min(lo, w.Conf)
+						n++
+					}
+				}
+			}
+		}
+	}
+	if n == 0 {
+		return 0, 0, 0
+	}
+	return sum / float64(n), lo, n
+}
+
+// Returns the mean of the known word confidences or 0 if none are known
+func meanConf(words []Word) float64 {
+	sum, n := 0.0, 0
+	for _, w := range words {
+		if w.Conf > 0 {
+			sum += w.Conf
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// Draws the polygon if there is one. Otherwise draws the bounds
+func drawBounds(img draw.Image, bounds, poly string, c color.Color) error {
+	if poly != "" {
+		ps, err := DecodePoly(poly)
+		if err != nil {
+			return err
+		}
+		if len(ps) == 4 {
+			bresenham.Poly(img, ps[0], ps[1], ps[2], ps[3], c, 1)
+		} else {
+			for i := range ps {
+				bresenham.Line(img, ps[i], ps[(i+1)%len(ps)], c, 1)
+			}
+		}
+		return nil
+	}
+	x, y, w, h, err := decodeRawBounds(bounds)
+	if err != nil {
+		return err
+	}
+	bresenham.Rect(img, image.Point{x, y}, w, h, c, 1)
+	return nil
+}
+
+// Annotates a single-page detection on the image
 func (d *Detection) Annotate(src []byte, c color.Color, ab, al, aw bool) ([]byte, error) {
 	m, _, err := image.Decode(bytes.NewReader(src))
 	if err != nil {
 		return nil, err
 	}
+	page, err := d.onlyPage()
+	if err != nil {
+		return nil, err
+	}
 	img := image.NewRGBA(m.Bounds())
 	draw.Draw(img, img.Bounds(), m, image.ZP, draw.Src)
-	for _, block := range d.Blocks {
-		x, y, w, h, err := decodeRawBounds(block.Bounds)
-		if err != nil {
-			return nil, err
-		}
+	for _, block := range page.Blocks {
 		if ab {
-			bresenham.Rect(img, image.Point{x, y}, w, h, c, 1)
-		}
-		for _, line := range block.Lines {
-			x, y, w, h, err = decodeRawBounds(line.Bounds)
-			if err != nil {
+			if err := drawBounds(img, block.Bounds, block.Poly, c); err != nil {
 				return nil, err
 			}
+		}
+		for _, line := range block.Lines {
 			if al {
-				bresenham.Rect(img, image.Point{x, y}, w, h, c, 1)
-			}
-			for _, word := range line.Words {
-				x, y, w, h, err = decodeRawBounds(word.Bounds)
-				if err != nil {
+				if err := drawBounds(img, line.Bounds, line.Poly, c); err != nil {
 					return nil, err
 				}
+			}
+			for _, word := range line.Words {
 				if aw {
-					bresenham.Rect(img, image.Point{x, y}, w, h, c, 1)
+					if err := drawBounds(img, word.Bounds, word.Poly, c); err != nil {
+						return nil, err
+					}
 				}
 			}
 		}
@@ -171,6 +286,48 @@ func DecodeBounds(bounds string) (Bounds, error) {
 	return Bounds{int(x0), int(y0), int(x1), int(y1)}, nil
 }
 
+// Returns the axis-aligned bounds of the points
+func pointsToBounds(ps []image.Point) string {
+	if len(ps) == 0 {
+		return encodeRawBounds(0, 0, 0, 0)
+	}
+	minx, miny := ps[0].X, ps[0].Y
+	maxx, maxy := minx, miny
+	for _, p := range ps[1:] {
+		minx, miny = min(minx, p.X), min(miny, p.Y)
+		maxx, maxy = max(maxx, p.X), max(maxy, p.Y)
+	}
+	return encodeRawBounds(minx, miny, maxx-minx, maxy-miny)
+}
+
+func encodePoly(ps []image.Point) string {
+	coords := make([]string, 0, 2*len(ps))
+	for _, p := range ps {
+		coords = append(coords, strconv.Itoa(p.X), strconv.Itoa(p.Y))
+	}
+	return strings.Join(coords, ",")
+}
+
+func DecodePoly(poly string) ([]image.Point, error) {
+	s := strings.Split(poly, ",")
+	if len(s) < 6 || len(s)%2 != 0 {
+		return nil, fmt.Errorf("Expected an even number of at least 6 fields. Found %d", len(s))
+	}
+	ps := make([]image.Point, 0, len(s)/2)
+	for i := 0; i < len(s); i += 2 {
+		x, err := strconv.Atoi(s[i])
+		if err != nil {
+			return nil, err
+		}
+		y, err := strconv.Atoi(s[i+1])
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, image.Point{x, y})
+	}
+	return ps, nil
+}
+
 func decodeRawBounds(bounds string) (int, int, int, int, error) {
 	b, err := DecodeBounds(bounds)
 	return b.X, b.Y, b.W, b.H, err
@@ -248,7 +405,19 @@ func lineSeparator(b0, b1 Bounds) (*image.Point, *image.Point, error) {
 
 func (d *Detection) Flatten() ([]bWord, error) {
 	var ws []bWord
-	for _, b := range d.Blocks {
+	for i := range d.Pages {
+		pws, err := d.Pages[i].flatten()
+		if err != nil {
+			return nil, err
+		}
+		ws = append(ws, pws...)
+	}
+	return ws, nil
+}
+
+func (p *Page) flatten() ([]bWord, error) {
+	var ws []bWord
+	for _, b := range p.Blocks {
 		for _, l := range b.Lines {
 			for _, w := range l.Words {
 				x0, y0, w0, h0, err := decodeRawBounds(w.Bounds)
@@ -280,9 +449,13 @@ func stddev(xs []int) float64 {
 	return math.Sqrt(sigma / float64(len(xs)-1))
 }
 
-// Attempts to find line boundaries and draw them
+// Attempts to find line boundaries on the only page and draw them
 func (d *Detection) AnnotateLineBoundaries(src []byte, c color.Color) ([]byte, error) {
-	bwords, err := d.Flatten()
+	page, err := d.onlyPage()
+	if err != nil {
+		return nil, err
+	}
+	bwords, err := page.flatten()
 	if err != nil {
 		return nil, err
 	}
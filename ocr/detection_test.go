@@ -1,6 +1,11 @@
 package ocr
 
 import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
 	"testing"
 )
 
@@ -37,3 +42,59 @@ func TestIntersectionLen(t *testing.T) {
 	assert(t, intersectionLen(l, r, l-2, l-1) == 0, "disjoint to left *")
 	assert(t, intersectionLen(l, r, r+1, r+2) == 0, "disjoint to right *")
 }
+
+func TestPoly(t *testing.T) {
+	poly := "10,10,50,12,48,30,8,28"
+	ps, err := DecodePoly(poly)
+	assert(t, err == nil && len(ps) == 4, "decode poly")
+	assert(t, ps[1].X == 50 && ps[1].Y == 12, "point order")
+	assert(t, encodePoly(ps) == poly, "encode poly")
+	_, err = DecodePoly("1,2,3,4")
+	assert(t, err != nil, "too few points")
+	_, err = DecodePoly("1,2,3,4,5")
+	assert(t, err != nil, "odd number of fields")
+	_, err = DecodePoly("1,2,3,4,5,x")
+	assert(t, err != nil, "non-numeric field")
+}
+
+func TestDecodeSinglePage(t *testing.T) {
+	raw := `{"algo":"aws-1_0","date":"","millis":1,"blocks":[{"xywh":"0,0,1,1","lines":[{"xywh":"0,0,1,1","words":[{"xywh":"0,0,1,1","text":"old"}]}]}]}`
+	var d Detection
+	assert(t, json.Unmarshal([]byte(raw), &d) == nil, "decode blocks")
+	assert(t, len(d.Pages) == 1 && d.Plaintext() == "old", "blocks become a single page")
+	assert(t, d.AlgoID == "aws-1_0" && d.Millis == 1, "detection fields")
+
+	encoded, err := json.Marshal(&d)
+	assert(t, err == nil, "encode pages")
+	var again Detection
+	assert(t, json.Unmarshal(encoded, &again) == nil, "decode pages")
+	assert(t, len(again.Pages) == 1 && again.Plaintext() == "old", "pages round trip")
+}
+
+func TestPlaintextPages(t *testing.T) {
+	page := func(text string) Page {
+		line := Line{Bounds: "0,0,1,1", Words: []Word{Word{Bounds: "0,0,1,1", Text: text}}}
+		return Page{Blocks: []Block{Block{Bounds: "0,0,1,1", Lines: []Line{line}}}}
+	}
+	d := Detection{Pages: []Page{page("one"), page("two")}}
+	assert(t, d.Plaintext() == "one\ftwo", "form feed between pages")
+	nb, _, nw := d.CountBLW()
+	assert(t, nb == 2 && nw == 2, "count every page")
+}
+
+func TestAnnotatePages(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 200, 100))); err != nil {
+		t.Fatal(err)
+	}
+	d := testDetection()
+	_, err := d.Annotate(buf.Bytes(), color.Black, true, true, true)
+	assert(t, err == nil, "annotate one page")
+
+	// The image is of a single page
+	d.Pages = append(d.Pages, d.Pages[0])
+	_, err = d.Annotate(buf.Bytes(), color.Black, true, true, true)
+	assert(t, err != nil, "annotate several pages")
+	_, err = d.AnnotateLineBoundaries(buf.Bytes(), color.Black)
+	assert(t, err != nil, "annotate line boundaries of several pages")
+}
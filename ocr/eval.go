@@ -0,0 +1,110 @@
+package ocr
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Counts of words matched between a detection and its ground truth. Words
+// match if their boxes overlap enough. Matched words are correct if their
+// text is the same
+type Evaluation struct {
+	Truth     int // Ground truth words
+	Predicted int // Detected words
+	Matched   int // Pairs of a ground truth and detected word
+	Correct   int // Matched pairs with the same text
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+// Fraction of detected words that match a ground truth word
+func (e Evaluation) Precision() float64 { return ratio(e.Matched, e.Predicted) }
+
+// Fraction of ground truth words that match a detected word
+func (e Evaluation) Recall() float64 { return ratio(e.Matched, e.Truth) }
+
+// Harmonic mean of precision and recall
+func (e Evaluation) F1() float64 { return ratio(2*e.Matched, e.Truth+e.Predicted) }
+
+// Fraction of matched words that were recognized correctly
+func (e Evaluation) Accuracy() float64 { return ratio(e.Correct, e.Matched) }
+
+// Adds the counts of another evaluation, such as of the next page
+func (e *Evaluation) Add(o Evaluation) {
+	e.Truth += o.Truth
+	e.Predicted += o.Predicted
+	e.Matched += o.Matched
+	e.Correct += o.Correct
+}
+
+// Returns the intersection over union of the bounds. 0 if both are empty
+func IoU(b0, b1 Bounds) float64 {
+	inter := intersectionArea(b0, b1)
+	return ratio(inter, b0.W*b0.H+b1.W*b1.H-inter)
+}
+
+// Matches the words one to one, greatest IoU first. Ties go to the earliest
+// ground truth word, then the earliest detected word
+func matchWords(truth, pred []bWord, threshold float64) Evaluation {
+	type pair struct {
+		t, p int
+		iou  float64
+	}
+	var pairs []pair
+	for i, t := range truth {
+		for j, p := range pred {
+			if !overlaps(t.b, p.b) {
+				continue
+			}
+			if iou := IoU(t.b, p.b); iou >= threshold && iou > 0 {
+				pairs = append(pairs, pair{i, j, iou})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].iou > pairs[j].iou })
+
+	e := Evaluation{Truth: len(truth), Predicted: len(pred)}
+	usedT, usedP := make([]bool, len(truth)), make([]bool, len(pred))
+	for _, m := range pairs {
+		if usedT[m.t] || usedP[m.p] {
+			continue
+		}
+		usedT[m.t], usedP[m.p] = true, true
+		e.Matched++
+		if truth[m.t].t == pred[m.p].t {
+			e.Correct++
+		}
+	}
+	return e
+}
+
+// Evaluates the words of the detection against the ground truth page by
+// page. Words match if the IoU of their boxes is at least threshold, i.e.
+// 0.5. Pages missing from either side count as unmatched
+func Evaluate(truth, pred *Detection, threshold float64) (Evaluation, error) {
+	if threshold < 0 || threshold > 1 {
+		return Evaluation{}, fmt.Errorf("Expected an IoU threshold in [0, 1] instead of: %v", threshold)
+	}
+	var e Evaluation
+	for i := 0; i < len(truth.Pages) || i < len(pred.Pages); i++ {
+		var tws, pws []bWord
+		var err error
+		if i < len(truth.Pages) {
+			if tws, err = truth.Pages[i].flatten(); err != nil {
+				return Evaluation{}, fmt.Errorf("truth page %d: %v", i+1, err)
+			}
+		}
+		if i < len(pred.Pages) {
+			if pws, err = pred.Pages[i].flatten(); err != nil {
+				return Evaluation{}, fmt.Errorf("page %d: %v", i+1, err)
+			}
+		}
+		e.Add(matchWords(tws, pws, threshold))
+	}
+	return e, nil
+}
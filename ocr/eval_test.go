@@ -0,0 +1,69 @@
+package ocr
+
+import (
+	"math"
+	"testing"
+)
+
+func TestIoU(t *testing.T) {
+	b := Bounds{0, 0, 10, 10}
+	assert(t, IoU(b, b) == 1, "iou identical")
+	assert(t, IoU(b, Bounds{20, 20, 10, 10}) == 0, "iou disjoint")
+	assert(t, IoU(b, Bounds{5, 0, 10, 10}) == 50.0/150.0, "iou half overlap")
+	assert(t, IoU(Bounds{}, Bounds{}) == 0, "iou empty")
+}
+
+func TestEvaluate(t *testing.T) {
+	truth := testDetection()
+	pred := testDetection()
+	pred.Pages[0].Blocks[0].Lines[0].Words = []Word{
+		{Bounds: "12,10,40,20", Text: "Hello"},  // IoU 0.905 with Hello
+		{Bounds: "85,10,50,20", Text: "world"},  // IoU 0.333 with <world>
+		{Bounds: "150,50,10,10", Text: "noise"}, // No overlap
+	}
+	e, err := Evaluate(truth, pred, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Evaluation{Truth: 2, Predicted: 3, Matched: 1, Correct: 1}
+	if e != want {
+		t.Fatalf("Expected %+v. Received: %+v", want, e)
+	}
+	assert(t, e.Precision() == 1.0/3 && e.Recall() == 0.5 && e.Accuracy() == 1, "iou 0.5 scores")
+	assert(t, math.Abs(e.F1()-0.4) < 1e-9, "f1")
+
+	e, err = Evaluate(truth, pred, 0.3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = Evaluation{Truth: 2, Predicted: 3, Matched: 2, Correct: 1}
+	if e != want {
+		t.Fatalf("Expected %+v. Received: %+v", want, e)
+	}
+
+	// A missing page leaves its words unmatched
+	truth.Pages = append(truth.Pages, truth.Pages[0])
+	e, err = Evaluate(truth, pred, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, e.Truth == 4 && e.Matched == 1, "missing page")
+
+	_, err = Evaluate(truth, pred, 1.5)
+	assert(t, err != nil, "invalid threshold")
+}
+
+func TestEvaluateOneToOne(t *testing.T) {
+	truth := testDetection()
+	pred := testDetection()
+	words := pred.Pages[0].Blocks[0].Lines[0].Words
+	pred.Pages[0].Blocks[0].Lines[0].Words = append(words, Word{Bounds: "10,10,40,20", Text: "Hello"})
+	e, err := Evaluate(truth, pred, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Evaluation{Truth: 2, Predicted: 3, Matched: 2, Correct: 2}
+	if e != want {
+		t.Fatalf("Expected %+v. Received: %+v", want, e)
+	}
+}
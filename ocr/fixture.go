@@ -0,0 +1,146 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// Fixture is a recorded response of an OCR provider. HTTP providers record
+// the status, headers and body. gRPC providers record the reply as json
+type Fixture struct {
+	Method string      `json:"method"`
+	Host   string      `json:"host"`
+	Path   string      `json:"path"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+}
+
+// Clients that can record their responses with a Recorder
+type Recordable interface {
+	Client
+	// Returns a copy of the client that sends its requests through r
+	WithRecorder(r *Recorder) Client
+}
+
+// Recorder captures every response a client receives. It is an
+// http.RoundTripper for HTTP providers and a gRPC interceptor for gRPC ones
+type Recorder struct {
+	Transport http.RoundTripper // Defaults to http.DefaultTransport
+
+	mu       sync.Mutex
+	fixtures []Fixture
+}
+
+func (r *Recorder) add(f Fixture) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fixtures = append(r.fixtures, f)
+}
+
+// Method required by http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	response, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.add(Fixture{req.Method, req.URL.Host, req.URL.Path, response.StatusCode, response.Header.Clone(), string(body)})
+	return response, nil
+}
+
+// Records the replies of unary gRPC calls
+func (r *Recorder) intercept(ctx context.Context, method string, req, reply interface{},
+	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+		return err
+	}
+	body, err := json.Marshal(reply)
+	if err != nil {
+		return err
+	}
+	r.add(Fixture{"GRPC", cc.Target(), method, http.StatusOK, nil, string(body)})
+	return nil
+}
+
+// Returns the recorded responses in the order they were received
+func (r *Recorder) Fixtures() []Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Fixture(nil), r.fixtures...)
+}
+
+// Writes the recorded responses to a json file
+func (r *Recorder) Save(filename string) error {
+	encoded, err := json.MarshalIndent(r.Fixtures(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, encoded, 0600)
+}
+
+// Reads fixtures written by Recorder.Save
+func LoadFixtures(filename string) ([]Fixture, error) {
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var fixtures []Fixture
+	if err := json.Unmarshal(raw, &fixtures); err != nil {
+		return nil, err
+	}
+	return fixtures, nil
+}
+
+// Returns a handler that answers each request with the next fixture, in
+// order, as a stand-in for an HTTP provider. Absolute URLs in headers that
+// point at the recorded host, such as Azure's Operation-Location, are
+// rewritten to point at the stand-in instead
+func NewReplayHandler(fixtures []Fixture) http.Handler {
+	var mu sync.Mutex
+	next := 0
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		i := next
+		next++
+		mu.Unlock()
+		if i >= len(fixtures) {
+			http.Error(w, fmt.Sprintf("no fixture left for request %d", i), http.StatusNotImplemented)
+			return
+		}
+		f := fixtures[i]
+		if f.Method != req.Method || f.Path != req.URL.Path {
+			http.Error(w, fmt.Sprintf("fixture %d is %s %s. Received %s %s", i, f.Method, f.Path, req.Method, req.URL.Path), http.StatusNotImplemented)
+			return
+		}
+		for k, vs := range f.Header {
+			for _, v := range vs {
+				if f.Host != "" {
+					v = strings.Replace(v, "https://"+f.Host, "http://"+req.Host, 1)
+					v = strings.Replace(v, "http://"+f.Host, "http://"+req.Host, 1)
+				}
+				w.Header().Add(k, v)
+			}
+		}
+		w.Header().Del("Content-Length")
+		w.WriteHeader(f.Status)
+		w.Write([]byte(f.Body))
+	})
+}
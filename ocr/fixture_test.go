@@ -0,0 +1,234 @@
+package ocr
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/api/option"
+	pb "google.golang.org/genproto/googleapis/cloud/vision/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Starts a stand-in provider replaying the fixture file in testdata
+func replay(t *testing.T, name string) *httptest.Server {
+	fixtures, err := LoadFixtures(path.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Cannot load fixtures %s: %v", name, err)
+	}
+	server := httptest.NewServer(NewReplayHandler(fixtures))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Writes the files into a new credentials directory
+func keysDir(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, contents := range files {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func azureKeys(t *testing.T, server *httptest.Server) string {
+	return keysDir(t, map[string]string{
+		"azure.json": `{"subscription_key": "test", "endpoint": "` + server.URL + `/"}`,
+	})
+}
+
+// Checks the detection of the "Hello world" fixtures
+func checkHelloWorld(t *testing.T, c Client, result *Result) {
+	detection, err := c.ResultToDetection(result, 200, 100)
+	if err != nil {
+		t.Fatalf("ResultToDetection: %v", err)
+	}
+	if text := detection.Plaintext(); text != "Hello world" {
+		t.Fatalf("Expected 'Hello world'. Received: %q", text)
+	}
+}
+
+func TestAzureReplay(t *testing.T) {
+	server := replay(t, "azure.json")
+	c := AzureClient{CredentialsPath: azureKeys(t, server)}
+	result, err := c.Run([]byte("image"))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	assert(t, result.Service == "Azure" && result.FullText == "Hello world", "azure result")
+	checkHelloWorld(t, c, result)
+}
+
+func TestAzureStatusCode(t *testing.T) {
+	server := replay(t, "azure_unauthorized.json")
+	c := AzureClient{CredentialsPath: azureKeys(t, server)}
+	_, err := c.Run([]byte("image"))
+	assert(t, err != nil && strings.Contains(err.Error(), "401"), "azure status code")
+}
+
+func TestAzureReadReplay(t *testing.T) {
+	server := replay(t, "azure_read.json")
+	c := AzureReadClient{CredentialsPath: azureKeys(t, server), PollInterval: time.Millisecond}
+	result, err := c.Run([]byte("image"))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	assert(t, result.Version == "3.1.0" && result.FullText == "Hello world", "azure read result")
+	checkHelloWorld(t, c, result)
+	detection, _ := c.ResultToDetection(result, 200, 100)
+	_, lo, n := detection.Confidence()
+	assert(t, n == 2 && lo == 0.87, "azure read confidence")
+	page := detection.Pages[0]
+	assert(t, page.Width == 200 && page.Height == 100 && page.Lang == "en", "azure read page")
+	assert(t, detection.Pages[0].Blocks[0].Lines[0].Words[0].Poly == "10,10,50,10,50,30,10,30", "azure read polygon")
+}
+
+func TestAzureReadFailed(t *testing.T) {
+	server := replay(t, "azure_read_failed.json")
+	c := AzureReadClient{CredentialsPath: azureKeys(t, server), PollInterval: time.Millisecond}
+	_, err := c.Run([]byte("image"))
+	assert(t, err != nil && strings.Contains(err.Error(), "has failed"), "azure read failed status")
+}
+
+func TestAzureReadDeadline(t *testing.T) {
+	server := replay(t, "azure_read.json")
+	c := AzureReadClient{CredentialsPath: azureKeys(t, server), PollInterval: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.RunContext(ctx, []byte("image"))
+	assert(t, err != nil && strings.Contains(err.Error(), "deadline"), "azure read deadline")
+}
+
+func TestAWSReplay(t *testing.T) {
+	server := replay(t, "aws.json")
+	keys := keysDir(t, map[string]string{
+		"credentials": "[default]\naws_access_key_id = test\naws_secret_access_key = test\n",
+		"config":      "[default]\nregion = us-east-1\n",
+	})
+	c := AWSClient{CredentialsPath: keys, Endpoint: server.URL}
+	result, err := c.Run([]byte("image"))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	assert(t, result.Version == "1.0" && result.FullText == "Hello world", "aws result")
+	checkHelloWorld(t, c, result)
+	detection, _ := c.ResultToDetection(result, 200, 100)
+	assert(t, detection.Pages[0].Blocks[0].Lines[0].Conf == 0.985, "aws line confidence")
+	assert(t, detection.Pages[0].Blocks[0].Lines[0].Poly == "10,10,110,10,110,30,10,30", "aws line polygon")
+}
+
+// Stand-in for GCP Vision replaying a recorded BatchAnnotateImages reply
+type gcpReplay struct {
+	pb.UnimplementedImageAnnotatorServer
+	reply pb.BatchAnnotateImagesResponse
+}
+
+func (s *gcpReplay) BatchAnnotateImages(context.Context, *pb.BatchAnnotateImagesRequest) (*pb.BatchAnnotateImagesResponse, error) {
+	return &s.reply, nil
+}
+
+func TestGCPReplay(t *testing.T) {
+	fixtures, err := LoadFixtures(path.Join("testdata", "gcp.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stand := &gcpReplay{}
+	if err := json.Unmarshal([]byte(fixtures[0].Body), &stand.reply); err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	pb.RegisterImageAnnotatorServer(server, stand)
+	go server.Serve(lis)
+	defer server.Stop()
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	c := GCPClient{Options: []option.ClientOption{option.WithGRPCConn(conn)}}
+	result, err := c.Run([]byte("image"))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	assert(t, result.FullText == "Hello world\n", "gcp result")
+	checkHelloWorld(t, c, result)
+}
+
+// Stand-in for GCP Vision that is unavailable to every call
+type gcpUnavailable struct {
+	pb.UnimplementedImageAnnotatorServer
+	calls int32
+}
+
+func (s *gcpUnavailable) BatchAnnotateImages(context.Context, *pb.BatchAnnotateImagesRequest) (*pb.BatchAnnotateImagesResponse, error) {
+	atomic.AddInt32(&s.calls, 1)
+	return nil, status.Error(codes.Unavailable, "unavailable")
+}
+
+func TestGCPRetry(t *testing.T) {
+	stand := &gcpUnavailable{}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	pb.RegisterImageAnnotatorServer(server, stand)
+	go server.Serve(lis)
+	defer server.Stop()
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Only the RetryPolicy retries, not the client library
+	c := GCPClient{Options: []option.ClientOption{option.WithGRPCConn(conn)}, Retry: fastRetry}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = c.RunContext(ctx, []byte("image"))
+	assert(t, err != nil && strings.Contains(err.Error(), "after 3 attempts"), "gcp attempts in error")
+	assert(t, atomic.LoadInt32(&stand.calls) == 3, "one call per attempt")
+}
+
+func TestRecorder(t *testing.T) {
+	server := replay(t, "azure_read.json")
+	r := &Recorder{}
+	c := AzureReadClient{CredentialsPath: azureKeys(t, server), PollInterval: time.Millisecond}
+	if _, err := c.WithRecorder(r).Run([]byte("image")); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	recorded := r.Fixtures()
+	assert(t, len(recorded) == 4, "one fixture per response")
+	assert(t, recorded[0].Status == 202 && recorded[3].Method == "GET", "fixture order")
+	assert(t, strings.Contains(recorded[3].Body, "succeeded"), "fixture body")
+
+	// Replaying the recording yields the same result
+	filename := path.Join(t.TempDir(), "fixtures.json")
+	if err := r.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	fixtures, err := LoadFixtures(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again := httptest.NewServer(NewReplayHandler(fixtures))
+	defer again.Close()
+	c.CredentialsPath = azureKeys(t, again)
+	result, err := c.Run([]byte("image"))
+	assert(t, err == nil && result.FullText == "Hello world", "replay of a recording")
+}
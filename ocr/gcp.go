@@ -5,6 +5,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/color"
 	"path"
 	"strings"
 	"time"
@@ -12,16 +14,39 @@ import (
 	vision "cloud.google.com/go/vision/apiv1"
 	"google.golang.org/api/option"
 	pb "google.golang.org/genproto/googleapis/cloud/vision/v1"
+	"google.golang.org/grpc"
 )
 
 type GCPClient struct {
 	CredentialsPath string
+	Options         []option.ClientOption // Optional. Applied after the credentials
+	Retry           RetryPolicy           // Optional. Defaults to DefaultRetryPolicy
+}
+
+func init() {
+	Register(Provider{
+		Name:    "gcp",
+		Key:     "gcp",
+		Service: "GCP",
+		AlgoID:  "gcp",
+		Usage: "Run GCP Vision OCR. Key file: gcp.json\n" +
+			"More info: https://cloud.google.com/vision/docs/before-you-begin",
+		New:   func(keys string) Client { return GCPClient{CredentialsPath: keys} },
+		Color: color.RGBA{255, 0, 0, 255}, // Red
+		// https://cloud.google.com/vision/pricing (Document Text Detection)
+		Pricing: Pricing{{1000, 0}, {5000000, 0.0015}, {0, 0.0006}},
+	})
 }
 
 // Method required by ocr.Client
 // Returns GCP document text detection Result
 // Reference: https://cloud.google.com/vision/docs/apis
 func (c GCPClient) Run(file []byte) (*Result, error) {
+	return c.RunContext(context.Background(), file)
+}
+
+// Method required by ocr.Client
+func (c GCPClient) RunContext(ctx context.Context, file []byte) (*Result, error) {
 	const (
 		service = "GCP"
 		version = "v1"
@@ -29,15 +54,14 @@ func (c GCPClient) Run(file []byte) (*Result, error) {
 	)
 
 	credentialsFile := path.Join(c.CredentialsPath, keyName)
-	ctx := context.Background()
-	client, err := vision.NewImageAnnotatorClient(
-		ctx,
-		option.WithCredentialsFile(credentialsFile),
-	)
+	opts := append([]option.ClientOption{option.WithCredentialsFile(credentialsFile)}, c.Options...)
+	client, err := vision.NewImageAnnotatorClient(ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("%s: configuration error: %v", service, err)
 	}
 	defer client.Close()
+	// RetryPolicy retries instead, so that every attempt is counted and timed
+	client.CallOptions.BatchAnnotateImages = nil
 
 	fileReader := bytes.NewReader(file)
 	image, err := vision.NewImageFromReader(fileReader)
@@ -45,11 +69,21 @@ func (c GCPClient) Run(file []byte) (*Result, error) {
 		return nil, fmt.Errorf("%s: failed to read image bytes: %v", service, err)
 	}
 
-	start := time.Now()
-	annotation, err := client.DetectDocumentText(ctx, image, nil)
-	milli := int64(time.Since(start) / time.Millisecond)
+	// Only the duration of the successful attempt is reported
+	var start time.Time
+	var milli int64
+	var annotation *pb.TextAnnotation
+	attempts, err := c.Retry.Do(ctx, func() error {
+		start = time.Now()
+		annotation, err = client.DetectDocumentText(ctx, image, nil)
+		milli = int64(time.Since(start) / time.Millisecond)
+		if err != nil {
+			return fmt.Errorf("%s: OCR request failed - %w", service, err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("%s: OCR request failed - %v", service, err)
+		return nil, err
 	}
 
 	// Extract full text
@@ -68,9 +102,17 @@ func (c GCPClient) Run(file []byte) (*Result, error) {
 		Duration: milli,
 		Date:     date,
 		Raw:      encoded,
+		Attempts: attempts,
 	}, err
 }
 
+// Method required by ocr.Recordable
+func (c GCPClient) WithRecorder(r *Recorder) Client {
+	interceptor := option.WithGRPCDialOption(grpc.WithUnaryInterceptor(r.intercept))
+	c.Options = append(append([]option.ClientOption{}, c.Options...), interceptor)
+	return c
+}
+
 func polyToBox(poly *pb.BoundingPoly) (string, error) {
 	if len(poly.Vertices) != 4 {
 		return "", fmt.Errorf("Found %d != 4 vertices", len(poly.Vertices))
@@ -94,6 +136,14 @@ func polyToBox(poly *pb.BoundingPoly) (string, error) {
 	return encodeRawBounds(int(minx), int(miny), int(maxx-minx), int(maxy-miny)), nil
 }
 
+func verticesToPoly(poly *pb.BoundingPoly) string {
+	ps := make([]image.Point, 0, len(poly.Vertices))
+	for _, v := range poly.Vertices {
+		ps = append(ps, image.Point{int(v.X), int(v.Y)})
+	}
+	return encodePoly(ps)
+}
+
 func (_ GCPClient) ResultToDetection(result *Result, _, _ int) (*Detection, error) {
 	var response pb.TextAnnotation
 	err := json.Unmarshal(result.Raw, &response)
@@ -101,8 +151,9 @@ func (_ GCPClient) ResultToDetection(result *Result, _, _ int) (*Detection, erro
 		return nil, err
 	}
 
-	blocks := make([]Block, 0, 3)
+	pages := make([]Page, 0, len(response.Pages))
 	for _, p := range response.Pages {
+		blocks := make([]Block, 0, len(p.Blocks))
 		for _, r := range p.Blocks {
 			lines := make([]Line, 0, len(r.Paragraphs))
 			for _, l := range r.Paragraphs {
@@ -117,22 +168,27 @@ func (_ GCPClient) ResultToDetection(result *Result, _, _ int) (*Detection, erro
 						symbols = append(symbols, s.Text)
 					}
 					word := strings.Join(symbols, "")
-					words = append(words, Word{bounds, word})
+					words = append(words, Word{Bounds: bounds, Text: word, Conf: float64(w.Confidence), Poly: verticesToPoly(w.BoundingBox)})
 				}
 				bounds, err := polyToBox(l.BoundingBox)
 				if err != nil {
 					return nil, err
 				}
-				lines = append(lines, Line{bounds, words})
+				lines = append(lines, Line{Bounds: bounds, Words: words, Conf: float64(l.Confidence), Poly: verticesToPoly(l.BoundingBox)})
 			}
 			bounds, err := polyToBox(r.BoundingBox)
 			if err != nil {
 				return nil, err
 			}
-			blocks = append(blocks, Block{bounds, lines})
+			blocks = append(blocks, Block{Bounds: bounds, Lines: lines, Poly: verticesToPoly(r.BoundingBox)})
+		}
+		page := Page{Width: int(p.Width), Height: int(p.Height), Blocks: blocks}
+		if langs := p.GetProperty().GetDetectedLanguages(); len(langs) > 0 {
+			page.Lang = langs[0].LanguageCode
 		}
+		pages = append(pages, page)
 	}
 	algoID := sanitizeString(result.Service[:3] + "-" + result.Version)
 	millis := uint32(result.Duration)
-	return &Detection{algoID, result.Date, millis, blocks}, nil
+	return &Detection{algoID, result.Date, millis, pages}, nil
 }
@@ -0,0 +1,99 @@
+package ocr
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+const hocrHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Transitional//EN"
+    "http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd">
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="en" lang="en">
+ <head>
+  <title></title>
+  <meta http-equiv="Content-Type" content="text/html;charset=utf-8"/>
+  <meta name="ocr-system" content="tigerocr %s"/>
+  <meta name="ocr-capabilities" content="ocr_page ocr_carea ocr_line ocrx_word"/>
+ </head>
+ <body>
+`
+
+// Returns the hOCR title bbox of "x,y,w,h" bounds
+func hocrBBox(bounds string) (string, error) {
+	b, err := DecodeBounds(bounds)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("bbox %d %d %d %d", b.X, b.Y, b.X+b.W, b.Y+b.H), nil
+}
+
+// Returns the bounds of the page, or the union of its blocks if its size is
+// unknown
+func (p *Page) bounds() (Bounds, error) {
+	if p.Width > 0 && p.Height > 0 {
+		return Bounds{0, 0, p.Width, p.Height}, nil
+	}
+	maxX, maxY := 0, 0
+	for _, b := range p.Blocks {
+		bb, err := DecodeBounds(b.Bounds)
+		if err != nil {
+			return Bounds{}, err
+		}
+		maxX, maxY = max(maxX, bb.X+bb.W), max(maxY, bb.Y+bb.H)
+	}
+	return Bounds{0, 0, maxX, maxY}, nil
+}
+
+// Returns the detection as hOCR (kba.github.io/hocr-spec/1.2/). Blocks are
+// ocr_carea, lines ocr_line and words ocrx_word. Word confidences are x_wconf
+func (d *Detection) HOCR() ([]byte, error) {
+	var s strings.Builder
+	fmt.Fprintf(&s, hocrHeader, html.EscapeString(d.AlgoID))
+	for pi, p := range d.Pages {
+		pb, err := p.bounds()
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %v", pi+1, err)
+		}
+		lang := ""
+		if p.Lang != "" {
+			lang = fmt.Sprintf(` lang="%s"`, html.EscapeString(p.Lang))
+		}
+		fmt.Fprintf(&s, "  <div class=\"ocr_page\" id=\"page_%d\"%s title=\"bbox 0 0 %d %d; ppageno %d\">\n", pi+1, lang, pb.W, pb.H, pi)
+		nl, nw := 0, 0
+		for bi, b := range p.Blocks {
+			bbox, err := hocrBBox(b.Bounds)
+			if err != nil {
+				return nil, fmt.Errorf("page %d block %d: %v", pi+1, bi+1, err)
+			}
+			fmt.Fprintf(&s, "   <div class=\"ocr_carea\" id=\"block_%d_%d\" title=\"%s\">\n", pi+1, bi+1, bbox)
+			for _, l := range b.Lines {
+				nl++
+				bbox, err := hocrBBox(l.Bounds)
+				if err != nil {
+					return nil, fmt.Errorf("page %d line %d: %v", pi+1, nl, err)
+				}
+				fmt.Fprintf(&s, "    <span class=\"ocr_line\" id=\"line_%d_%d\" title=\"%s\">", pi+1, nl, bbox)
+				for wi, w := range l.Words {
+					nw++
+					bbox, err := hocrBBox(w.Bounds)
+					if err != nil {
+						return nil, fmt.Errorf("page %d word %d: %v", pi+1, nw, err)
+					}
+					if w.Conf > 0 {
+						bbox += fmt.Sprintf("; x_wconf %d", int(w.Conf*100+0.5))
+					}
+					if wi > 0 {
+						s.WriteString(" ")
+					}
+					fmt.Fprintf(&s, "<span class=\"ocrx_word\" id=\"word_%d_%d\" title=\"%s\">%s</span>", pi+1, nw, bbox, html.EscapeString(w.Text))
+				}
+				s.WriteString("</span>\n")
+			}
+			s.WriteString("   </div>\n")
+		}
+		s.WriteString("  </div>\n")
+	}
+	s.WriteString(" </body>\n</html>\n")
+	return []byte(s.String()), nil
+}
@@ -0,0 +1,93 @@
+package ocr
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"testing"
+)
+
+func testDetection() *Detection {
+	words := []Word{
+		{Bounds: "10,10,40,20", Text: "Hello", Conf: 0.87},
+		{Bounds: "60,10,50,20", Text: "<world>"},
+	}
+	line := Line{Bounds: "10,10,100,20", Words: words}
+	page := Page{Width: 200, Height: 100, Lang: "en", Blocks: []Block{{Bounds: "10,10,100,20", Lines: []Line{line}}}}
+	return &Detection{AlgoID: "azu-test", Pages: []Page{page}}
+}
+
+// Returns the title and text of each element with a class, in order
+func hocrElements(t *testing.T, doc []byte) [][3]string {
+	var elems [][3]string
+	dec := xml.NewDecoder(bytes.NewReader(doc))
+	var open []int // Index into elems of the open elements, or -1
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Invalid xml: %v\n%s", err, doc)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			class, title := "", ""
+			for _, a := range tok.Attr {
+				switch a.Name.Local {
+				case "class":
+					class = a.Value
+				case "title":
+					title = a.Value
+				}
+			}
+			if class == "" {
+				open = append(open, -1)
+			} else {
+				open = append(open, len(elems))
+				elems = append(elems, [3]string{class, title, ""})
+			}
+		case xml.CharData:
+			if n := len(open); n > 0 && open[n-1] >= 0 {
+				elems[open[n-1]][2] += string(tok)
+			}
+		case xml.EndElement:
+			open = open[:len(open)-1]
+		}
+	}
+	return elems
+}
+
+func TestHOCR(t *testing.T) {
+	doc, err := testDetection().HOCR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	elems := hocrElements(t, doc)
+	want := [][3]string{
+		{"ocr_page", "bbox 0 0 200 100; ppageno 0", ""},
+		{"ocr_carea", "bbox 10 10 110 30", ""},
+		{"ocr_line", "bbox 10 10 110 30", ""},
+		{"ocrx_word", "bbox 10 10 50 30; x_wconf 87", "Hello"},
+		{"ocrx_word", "bbox 60 10 110 30", "<world>"},
+	}
+	if len(elems) != len(want) {
+		t.Fatalf("Expected %d elements. Received: %v", len(want), elems)
+	}
+	for i := range want {
+		got := elems[i]
+		if got[0] != want[i][0] || got[1] != want[i][1] || (want[i][2] != "" && got[2] != want[i][2]) {
+			t.Fatalf("Expected %v. Received: %v", want[i], got)
+		}
+	}
+}
+
+func TestHOCRPageSize(t *testing.T) {
+	d := testDetection()
+	d.Pages[0].Width, d.Pages[0].Height = 0, 0
+	doc, err := d.HOCR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	elems := hocrElements(t, doc)
+	assert(t, elems[0][1] == "bbox 0 0 110 30; ppageno 0", "page bbox from blocks")
+}
@@ -0,0 +1,237 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/color"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Runs a locally installed Tesseract. No credentials or network required
+type LocalClient struct {
+	Command string // Path to the tesseract executable. Defaults to PATH lookup
+}
+
+func init() {
+	Register(Provider{
+		Name:    "local",
+		Key:     "loc",
+		Service: "Local",
+		AlgoID:  "loc",
+		Usage: "Run local Tesseract OCR. No keys. Requires tesseract on PATH\n" +
+			"More info: https://tesseract-ocr.github.io/tessdoc/",
+		New:   func(_ string) Client { return LocalClient{} },
+		Color: color.RGBA{0, 128, 0, 255}, // Green
+	})
+}
+
+// Levels of the rows in Tesseract's tsv output
+const (
+	tsvPage  = 1
+	tsvBlock = 2
+	tsvPara  = 3
+	tsvLine  = 4
+	tsvWord  = 5
+)
+
+func (c LocalClient) command() string {
+	if c.Command == "" {
+		return "tesseract"
+	}
+	return c.Command
+}
+
+// Version of each tesseract executable, asked once
+var (
+	versionsMu sync.Mutex
+	versions   = make(map[string]*tesseractVersionOnce)
+)
+
+type tesseractVersionOnce struct {
+	once    sync.Once
+	version string
+	err     error
+}
+
+// Returns the version reported by `tesseract --version`, i.e. 4.1.1. Asks
+// each executable only once
+func tesseractVersion(tesseract string) (string, error) {
+	versionsMu.Lock()
+	v, ok := versions[tesseract]
+	if !ok {
+		v = &tesseractVersionOnce{}
+		versions[tesseract] = v
+	}
+	versionsMu.Unlock()
+	v.once.Do(func() { v.version, v.err = askTesseractVersion(tesseract) })
+	return v.version, v.err
+}
+
+func askTesseractVersion(tesseract string) (string, error) {
+	// Older versions print the version to stderr instead of stdout
+	out, err := exec.Command(tesseract, "--version").CombinedOutput()
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) < 2 || fields[0] != "tesseract" {
+		return "", fmt.Errorf("unexpected version output: %s", string(out))
+	}
+	return fields[1], nil
+}
+
+// Method required by ocr.Client
+// Returns Tesseract document text detection Result. Raw is the tsv output
+// Reference: https://tesseract-ocr.github.io/tessdoc/Command-Line-Usage.html
+func (c LocalClient) Run(image []byte) (*Result, error) {
+	return c.RunContext(context.Background(), image)
+}
+
+// Method required by ocr.Client
+func (c LocalClient) RunContext(ctx context.Context, image []byte) (*Result, error) {
+	const service = "Local"
+
+	tesseract := c.command()
+	version, err := tesseractVersion(tesseract)
+	if err != nil {
+		return nil, fmt.Errorf("%s: configuration error: %v", service, err)
+	}
+
+	cmd := exec.CommandContext(ctx, tesseract, "stdin", "stdout", "tsv")
+	cmd.Stdin = bytes.NewReader(image)
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+
+	start := time.Now()
+	out, err := cmd.Output()
+	milli := int64(time.Since(start) / time.Millisecond)
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("%s: OCR request failed - %v", service, ctx.Err())
+	} else if err != nil {
+		return nil, fmt.Errorf("%s: OCR request failed - %v: %s", service, err, strings.TrimSpace(stderr.String()))
+	}
+
+	pages, err := tsvToPages(out)
+	if err != nil {
+		return nil, fmt.Errorf("%s: cannot parse tsv output: %v", service, err)
+	}
+	fullText := (&Detection{Pages: pages}).Plaintext()
+
+	date := fmtTime(start.UTC())
+
+	return &Result{
+		Service:  service,
+		Version:  version,
+		FullText: fullText,
+		Duration: milli,
+		Date:     date,
+		Raw:      out,
+	}, nil
+}
+
+// Converts Tesseract tsv output to pages. Rows are expected in document
+// order: each page is followed by its blocks, each block by its lines, each
+// line by its words. Paragraphs are skipped since their lines belong to the
+// enclosing block
+func tsvToPages(tsv []byte) ([]Page, error) {
+	rows := strings.Split(strings.TrimRight(string(tsv), "\n"), "\n")
+	pages := make([]Page, 0, 1)
+	var blocks []Block
+	for i, row := range rows {
+		if i == 0 && strings.HasPrefix(row, "level") {
+			continue // Header
+		}
+		fields := strings.SplitN(row, "\t", 12)
+		if len(fields) < 12 {
+			return nil, fmt.Errorf("Expected 12 fields. Found %d on row %d", len(fields), i)
+		}
+		var nums [10]int
+		for j := range nums {
+			n, err := strconv.Atoi(fields[j])
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %v", i, err)
+			}
+			nums[j] = n
+		}
+		bounds := encodeRawBounds(nums[6], nums[7], nums[8], nums[9])
+		if nums[0] == tsvPage || (nums[0] == tsvBlock && len(pages) == 0) {
+			if len(pages) > 0 {
+				pages[len(pages)-1].Blocks = nonEmpty(blocks)
+			}
+			pages = append(pages, Page{})
+			blocks = make([]Block, 0)
+		}
+		nb := len(blocks)
+		switch nums[0] {
+		case tsvPage:
+			pages[len(pages)-1].Width, pages[len(pages)-1].Height = nums[8], nums[9]
+		case tsvPara:
+			continue
+		case tsvBlock:
+			blocks = append(blocks, Block{Bounds: bounds, Lines: make([]Line, 0)})
+		case tsvLine:
+			if nb == 0 {
+				return nil, fmt.Errorf("Line before block on row %d", i)
+			}
+			blocks[nb-1].Lines = append(blocks[nb-1].Lines, Line{Bounds: bounds, Words: make([]Word, 0)})
+		case tsvWord:
+			nl := 0
+			if nb > 0 {
+				nl = len(blocks[nb-1].Lines)
+			}
+			if nl == 0 {
+				return nil, fmt.Errorf("Word before line on row %d", i)
+			}
+			text := strings.TrimSpace(fields[11])
+			if text == "" {
+				continue // Tesseract reports empty words for some images
+			}
+			line := &blocks[nb-1].Lines[nl-1]
+			conf, err := strconv.ParseFloat(fields[10], 64)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %v", i, err)
+			}
+			line.Words = append(line.Words, Word{Bounds: bounds, Text: text, Conf: math.Max(conf, 0) / 100})
+		default:
+			return nil, fmt.Errorf("Invalid level: %d", nums[0])
+		}
+	}
+	if len(pages) > 0 {
+		pages[len(pages)-1].Blocks = nonEmpty(blocks)
+	}
+	return pages, nil
+}
+
+// Removes lines without words and blocks without lines
+func nonEmpty(blocks []Block) []Block {
+	kept := make([]Block, 0, len(blocks))
+	for _, b := range blocks {
+		lines := make([]Line, 0, len(b.Lines))
+		for _, l := range b.Lines {
+			if len(l.Words) > 0 {
+				l.Conf = meanConf(l.Words) // Tesseract reports -1 for lines
+				lines = append(lines, l)
+			}
+		}
+		if len(lines) > 0 {
+			kept = append(kept, Block{Bounds: b.Bounds, Lines: lines})
+		}
+	}
+	return kept
+}
+
+func (_ LocalClient) ResultToDetection(result *Result, _, _ int) (*Detection, error) {
+	pages, err := tsvToPages(result.Raw)
+	if err != nil {
+		return nil, err
+	}
+	algoID := sanitizeString(result.Service[:3] + "-" + result.Version)
+	millis := uint32(result.Duration)
+	return &Detection{algoID, result.Date, millis, pages}, nil
+}
@@ -0,0 +1,60 @@
+package ocr
+
+import (
+	"testing"
+)
+
+const tsvSample = `level	page_num	block_num	par_num	line_num	word_num	left	top	width	height	conf	text
+1	1	0	0	0	0	0	0	640	480	-1	
+2	1	1	0	0	0	36	92	582	94	-1	
+3	1	1	1	0	0	36	92	582	94	-1	
+4	1	1	1	1	0	36	92	582	33	-1	
+5	1	1	1	1	1	36	92	110	33	96.1	The
+5	1	1	1	1	2	157	92	137	33	95.8	quick
+4	1	1	1	2	0	36	153	300	33	-1	
+5	1	1	1	2	1	36	153	300	33	91.0	brown
+2	1	2	0	0	0	36	300	100	30	-1	
+3	1	2	1	0	0	36	300	100	30	-1	
+4	1	2	1	1	0	36	300	100	30	-1	
+5	1	2	1	1	1	36	300	100	30	-1	
+`
+
+func TestLocalResultToDetection(t *testing.T) {
+	result := Result{Service: "Local", Version: "4.1.1", Duration: 42, Raw: []byte(tsvSample)}
+	detection, err := LocalClient{}.ResultToDetection(&result, 0, 0)
+	assert(t, err == nil, "tsv parse error")
+	assert(t, detection.AlgoID == "loc-4_1_1", "algoid")
+	assert(t, detection.Millis == 42, "millis")
+	nb, nl, nw := detection.CountBLW()
+	assert(t, nb == 1 && nl == 2 && nw == 3, "empty blocks are removed")
+	assert(t, detection.Plaintext() == "The quick\nbrown", "plaintext")
+	assert(t, detection.Pages[0].Blocks[0].Lines[0].Words[1].Bounds == "157,92,137,33", "word bounds")
+	assert(t, detection.Pages[0].Blocks[0].Lines[0].Words[0].Conf == 0.961, "word confidence")
+	mean, lo, n := detection.Confidence()
+	assert(t, n == 3 && lo == 0.91 && mean > 0.94 && mean < 0.95, "detection confidence")
+}
+
+func TestLocalMalformed(t *testing.T) {
+	_, err := tsvToPages([]byte("5\t1\t1\t1\t1\t1\t0\t0\t1\t1\t90\tword\n"))
+	assert(t, err != nil, "word before line")
+	_, err = tsvToPages([]byte("2\t1\tx\t0\t0\t0\t0\t0\t1\t1\t-1\t\n"))
+	assert(t, err != nil, "non-numeric field")
+	_, err = tsvToPages([]byte("1\t1\t0\t0\t0\t0\t0\t0\t640\t480\t-1\n"))
+	assert(t, err != nil, "missing text field")
+}
+
+func TestLocalPages(t *testing.T) {
+	tsv := "1\t1\t0\t0\t0\t0\t0\t0\t640\t480\t-1\t\n" +
+		"2\t1\t1\t0\t0\t0\t0\t0\t10\t10\t-1\t\n" +
+		"4\t1\t1\t1\t1\t0\t0\t0\t10\t10\t-1\t\n" +
+		"5\t1\t1\t1\t1\t1\t0\t0\t10\t10\t90\tone\n" +
+		"1\t2\t0\t0\t0\t0\t0\t0\t320\t240\t-1\t\n" +
+		"2\t2\t1\t0\t0\t0\t0\t0\t10\t10\t-1\t\n" +
+		"4\t2\t1\t1\t1\t0\t0\t0\t10\t10\t-1\t\n" +
+		"5\t2\t1\t1\t1\t1\t0\t0\t10\t10\t90\ttwo\n"
+	pages, err := tsvToPages([]byte(tsv))
+	assert(t, err == nil && len(pages) == 2, "one page per page row")
+	assert(t, pages[1].Width == 320 && pages[1].Height == 240, "page size")
+	d := Detection{Pages: pages}
+	assert(t, d.Plaintext() == "one\ftwo", "page text")
+}
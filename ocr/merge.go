@@ -1,19 +1,449 @@
 package ocr
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ughe/tigerocr/kdtree"
 )
 
-func Merge(blws []Detection) (*Detection, error) {
-	fmt.Println("[INFO] hello, world")
-	// 1. Flatten
-	dets := make([][]bWord, len(blws))
-	for _, blw := range blws {
-		det, err := blw.Flatten()
+type point = [kdtree.K]kdtree.T
+
+// Word from one of the merged detections along with where it came from
+type mWord struct {
+	bWord
+	c      float64 // Confidence. 0 if unknown
+	poly   string  // Polygon. Empty if unknown
+	src    int     // Index of the source detection
+	bi, li int     // Block and line index within the source detection
+}
+
+// Words from different detections that are believed to be the same word.
+// Members are ordered by their source detection
+type cluster []*mWord
+
+// Center of the bounds as a k-d tree point. Negative coordinates are clamped
+func center(b Bounds) point {
+	return point{kdtree.T(max(b.X+b.W/2, 0)), kdtree.T(max(b.Y+b.H/2, 0))}
+}
+
+// Returns true if the point lies within the bounds (inclusive)
+func contains(b Bounds, p point) bool {
+	x, y := int(p[0]), int(p[1])
+	return x >= b.X && x <= b.X+b.W && y >= b.Y && y <= b.Y+b.H
+}
+
+// Returns the smallest bounds containing both b0 and b1
+func union(b0, b1 Bounds) Bounds {
+	x0, y0 := min(b0.X, b1.X), min(b0.Y, b1.Y)
+	x1, y1 := max(b0.X+b0.W, b1.X+b1.W), max(b0.Y+b0.H, b1.Y+b1.H)
+	return Bounds{x0, y0, x1 - x0, y1 - y0}
+}
+
+// Flattens the page, keeping track of where each word came from
+func (p *Page) flattenFrom(src int) ([]mWord, error) {
+	var ws []mWord
+	for i, b := range p.Blocks {
+		for j, l := range b.Lines {
+			for _, w := range l.Words {
+				bounds, err := DecodeBounds(w.Bounds)
+				if err != nil {
+					return nil, err
+				}
+				ws = append(ws, mWord{bWord{bounds, w.Text}, w.Conf, w.Poly, src, i, j})
+			}
+		}
+	}
+	return ws, nil
+}
+
+// Spatial index of the words of a single detection by their center points
+type wordIndex struct {
+	words   []mWord
+	used    []bool
+	root    *kdtree.Node
+	centers map[point][]int // Several words may share the same center
+}
+
+func newWordIndex(ws []mWord) *wordIndex {
+	idx := &wordIndex{ws, make([]bool, len(ws)), nil, make(map[point][]int)}
+	for i, w := range ws {
+		c := center(w.b)
+		if _, ok := idx.centers[c]; !ok {
+			if idx.root == nil {
+				idx.root = idx.root.Insert(c)
+			} else {
+				idx.root.Insert(c)
+			}
+		}
+		idx.centers[c] = append(idx.centers[c], i)
+	}
+	idx.root = idx.root.Optimize()
+	return idx
+}
+
+// Returns the indices of the words whose centers lie within b
+func (idx *wordIndex) within(b Bounds) []int {
+	lo := point{kdtree.T(max(b.X, 0)), kdtree.T(max(b.Y, 0))}
+	hi := point{kdtree.T(max(b.X+b.W, 0)), kdtree.T(max(b.Y+b.H, 0))}
+	var is []int
+	for _, n := range idx.root.RegionSearch([2]point{lo, hi}) {
+		is = append(is, idx.centers[n.Value()]...)
+	}
+	sort.Ints(is)
+	return is
+}
+
+// Finds the unused word that best matches w. Both words must contain each
+// other's centers. Ties in overlapping area go to the earliest word.
+// Returns -1 if there is no match
+func (idx *wordIndex) match(w *mWord) int {
+	best, bestArea := -1, 0
+	c := center(w.b)
+	for _, i := range idx.within(w.b) {
+		if idx.used[i] || !contains(idx.words[i].b, c) {
+			continue
+		}
+		if area := intersectionArea(w.b, idx.words[i].b); area > bestArea {
+			best, bestArea = i, area
+		}
+	}
+	return best
+}
+
+// Returns the mean of the known confidences of the members or 0 if none
+func (c cluster) meanConf() float64 {
+	sum, n := 0.0, 0
+	for _, w := range c {
+		if w.c > 0 {
+			sum += w.c
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// Returns a copy of the member whose text has the most weight. Each member
+// votes with its confidence. Members without one vote with the mean known
+// confidence of the cluster (or 1 if none is known). Ties go to the member
+// from the earliest detection. The copy's confidence is the mean known
+// confidence of the members that agree with it
+func (c cluster) vote() *mWord {
+	fallback := c.meanConf()
+	if fallback == 0 {
+		fallback = 1
+	}
+	weights := make(map[string]float64)
+	agree := make(map[string]cluster)
+	for _, w := range c {
+		if w.c > 0 {
+			weights[w.t] += w.c
+		} else {
+			weights[w.t] += fallback
+		}
+		agree[w.t] = append(agree[w.t], w)
+	}
+	best := c[0]
+	for _, w := range c[1:] {
+		if weights[w.t] > weights[best.t] {
+			best = w
+		}
+	}
+	winner := *best
+	winner.c = agree[best.t].meanConf()
+	return &winner
+}
+
+// Returns the member from detection src or nil
+func (c cluster) from(src int) *mWord {
+	for _, w := range c {
+		if w.src == src {
+			return w
+		}
+	}
+	return nil
+}
+
+// Aligns the words of every detection. Each word ends up in exactly one cluster
+func align(idxs []*wordIndex) []cluster {
+	var clusters []cluster
+	for i, idx := range idxs {
+		for k := range idx.words {
+			if idx.used[k] {
+				continue
+			}
+			idx.used[k] = true
+			c := cluster{&idx.words[k]}
+			for _, other := range idxs[i+1:] {
+				if m := other.match(&idx.words[k]); m >= 0 {
+					other.used[m] = true
+					c = append(c, &other.words[m])
+				}
+			}
+			clusters = append(clusters, c)
+		}
+	}
+	return clusters
+}
+
+// Groups words into lines of vertically overlapping words
+func groupLines(ws []*mWord) [][]*mWord {
+	sort.SliceStable(ws, func(i, j int) bool {
+		return ws[i].b.Y < ws[j].b.Y
+	})
+	var lines [][]*mWord
+	var last Bounds
+	for _, w := range ws {
+		n := len(lines)
+		if n > 0 && intersects(last.Y, last.Y+last.H, w.b.Y, w.b.Y+w.b.H) {
+			lines[n-1] = append(lines[n-1], w)
+			last = union(last, w.b)
+		} else {
+			lines = append(lines, []*mWord{w})
+			last = w.b
+		}
+	}
+	return lines
+}
+
+// Builds a line from the words, ordered left to right
+func newLine(bounds, poly string, ws []*mWord) Line {
+	sort.SliceStable(ws, func(i, j int) bool {
+		return ws[i].b.X < ws[j].b.X
+	})
+	words := make([]Word, 0, len(ws))
+	for _, w := range ws {
+		words = append(words, Word{Bounds: encodeBounds(w.b), Text: w.t, Conf: w.c, Poly: w.poly})
+	}
+	return Line{Bounds: bounds, Words: words, Conf: meanConf(words), Poly: poly}
+}
+
+// Lays out the winning words using the blocks and lines of the reference
+// page. Winners without a counterpart in the reference go to the line
+// they overlap the most. Remaining words are grouped into a trailing block
+func layout(ref *Page, src int, winners []*mWord, clusters []cluster) ([]Block, error) {
+	lineBounds := make([][]Bounds, len(ref.Blocks))
+	placed := make([][][]*mWord, len(ref.Blocks))
+	for i, b := range ref.Blocks {
+		lineBounds[i] = make([]Bounds, len(b.Lines))
+		placed[i] = make([][]*mWord, len(b.Lines))
+		for j, l := range b.Lines {
+			bounds, err := DecodeBounds(l.Bounds)
+			if err != nil {
+				return nil, err
+			}
+			lineBounds[i][j] = bounds
+		}
+	}
+
+	var orphans []*mWord
+	for k, w := range winners {
+		if r := clusters[k].from(src); r != nil {
+			placed[r.bi][r.li] = append(placed[r.bi][r.li], w)
+			continue
+		}
+		bi, bj, bestArea := -1, -1, 0
+		for i := range lineBounds {
+			for j := range lineBounds[i] {
+				if area := intersectionArea(w.b, lineBounds[i][j]); area > bestArea {
+					bi, bj, bestArea = i, j, area
+				}
+			}
+		}
+		if bi < 0 {
+			orphans = append(orphans, w)
+		} else {
+			placed[bi][bj] = append(placed[bi][bj], w)
+		}
+	}
+
+	blocks := make([]Block, 0, len(ref.Blocks)+1)
+	for i, b := range ref.Blocks {
+		lines := make([]Line, 0, len(b.Lines))
+		for j, l := range b.Lines {
+			if len(placed[i][j]) > 0 {
+				lines = append(lines, newLine(l.Bounds, l.Poly, placed[i][j]))
+			}
+		}
+		if len(lines) > 0 {
+			blocks = append(blocks, Block{Bounds: b.Bounds, Lines: lines, Poly: b.Poly})
+		}
+	}
+	if len(orphans) > 0 {
+		var lines []Line
+		blockBounds := orphans[0].b
+		for _, ws := range groupLines(orphans) {
+			bounds := ws[0].b
+			for _, w := range ws[1:] {
+				bounds = union(bounds, w.b)
+			}
+			blockBounds = union(blockBounds, bounds)
+			lines = append(lines, newLine(encodeBounds(bounds), "", ws))
+		}
+		blocks = append(blocks, Block{Bounds: encodeBounds(blockBounds), Lines: lines})
+	}
+	return blocks, nil
+}
+
+// Returns the AlgoID of the merge of the detections, i.e. merged-aws+gcp
+func mergedAlgoID(blws []Detection) string {
+	ids := make([]string, 0, len(blws))
+	for _, d := range blws {
+		ids = append(ids, strings.SplitN(d.AlgoID, "-", 2)[0])
+	}
+	return "merged-" + strings.Join(ids, "+")
+}
+
+type MergeStrategy int
+
+const (
+	MergeVote  MergeStrategy = iota // Keep words found by more than half of the detections
+	MergeBest                       // Keep the detection agreeing most with the vote, and its AlgoID
+	MergeUnion                      // Keep words found by any detection
+)
+
+func ParseMergeStrategy(s string) (MergeStrategy, error) {
+	switch s {
+	case "vote":
+		return MergeVote, nil
+	case "best":
+		return MergeBest, nil
+	case "union":
+		return MergeUnion, nil
+	default:
+		return 0, fmt.Errorf("Strategy %v is not {vote, best, union}", s)
+	}
+}
+
+// Returns the index of the detection whose words most often match the vote
+func best(n int, clusters []cluster, winners []*mWord) int {
+	agree := make([]int, n)
+	for k, c := range clusters {
+		for _, w := range c {
+			if w.t == winners[k].t {
+				agree[w.src]++
+			}
+		}
+	}
+	b := 0
+	for i, a := range agree {
+		if a > agree[b] {
+			b = i
+		}
+	}
+	return b
+}
+
+// Merges the detections of several providers into a consensus detection.
+// Words are aligned spatially across detections and the text of each group
+// of aligned words is chosen by majority vote. The strategy decides which
+// groups are kept. Pages are merged with the same page of the others. Best
+// keeps the AlgoID of its source if every page comes from the same one
+func Merge(blws []Detection, strategy MergeStrategy) (*Detection, error) {
+	if len(blws) == 0 {
+		return nil, errors.New("No detections to merge")
+	}
+
+	var millis uint32
+	npages := 0
+	for _, d := range blws {
+		if d.Millis > millis {
+			millis = d.Millis // Providers are assumed to run in parallel
+		}
+		npages = max(npages, len(d.Pages))
+	}
+	date := fmtTime(time.Now().UTC())
+
+	pages := make([]Page, 0, npages)
+	var sources []Detection // Of each page of the best, without duplicates
+	seen := make(map[int]bool)
+	for p := 0; p < npages; p++ {
+		ps := make([]*Page, len(blws))
+		for i := range blws {
+			if p < len(blws[i].Pages) {
+				ps[i] = &blws[i].Pages[p]
+			} else {
+				ps[i] = &Page{} // Missing pages have no words
+			}
+		}
+		page, src, err := mergePage(ps, strategy)
 		if err != nil {
 			return nil, err
 		}
-		dets = append(dets, det)
+		pages = append(pages, *page)
+		if src >= 0 && !seen[src] {
+			seen[src] = true
+			sources = append(sources, blws[src])
+		}
+	}
+	algoID := mergedAlgoID(blws)
+	if len(sources) == 1 {
+		algoID = sources[0].AlgoID
+	} else if len(sources) > 1 {
+		algoID = mergedAlgoID(sources)
+	}
+	return &Detection{algoID, date, millis, pages}, nil
+}
+
+// Returns the merged page. For MergeBest also the index of the page kept,
+// otherwise -1
+func mergePage(pages []*Page, strategy MergeStrategy) (*Page, int, error) {
+	// 1. Flatten and index each page by word centers
+	idxs := make([]*wordIndex, len(pages))
+	for i := range pages {
+		ws, err := pages[i].flattenFrom(i)
+		if err != nil {
+			return nil, -1, err
+		}
+		idxs[i] = newWordIndex(ws)
+	}
+
+	// 2. Align words across pages
+	clusters := align(idxs)
+
+	// 3. Vote on the text of each cluster found by enough pages
+	quorum := len(pages)/2 + 1 // Strict majority, so both of two
+	if strategy == MergeUnion {
+		quorum = 1
+	}
+	kept := make([]cluster, 0, len(clusters))
+	winners := make([]*mWord, 0, len(clusters))
+	support := make([]int, len(pages))
+	for _, c := range clusters {
+		if len(c) < quorum {
+			continue
+		}
+		kept = append(kept, c)
+		winners = append(winners, c.vote())
+		for _, w := range c {
+			support[w.src]++
+		}
+	}
+
+	if strategy == MergeBest {
+		src := best(len(pages), kept, winners)
+		b := *pages[src]
+		return &b, src, nil
+	}
+
+	// 4. Lay out words using the page that supports the most clusters
+	ref := 0
+	for i, n := range support {
+		if n > support[ref] {
+			ref = i
+		}
+	}
+	blocks, err := layout(pages[ref], ref, winners, kept)
+	if err != nil {
+		return nil, -1, err
 	}
-	return nil, nil
+	merged := *pages[ref]
+	merged.Blocks = blocks
+	return &merged, -1, nil
 }
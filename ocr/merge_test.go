@@ -0,0 +1,103 @@
+package ocr
+
+import (
+	"testing"
+)
+
+func word(bounds, text string) Word {
+	return Word{Bounds: bounds, Text: text}
+}
+
+// Returns a single page, single block, single line detection of the given words
+func lineOf(algoID string, words ...Word) Detection {
+	line := Line{Bounds: "0,0,1000,100", Words: words}
+	block := Block{Bounds: "0,0,1000,100", Lines: []Line{line}}
+	return Detection{algoID, "", 0, []Page{Page{Blocks: []Block{block}}}}
+}
+
+func TestMergeVote(t *testing.T) {
+	aws := lineOf("aws-1",
+		word("10,10,50,20", "The"),
+		word("70,10,60,20", "quick"),
+		word("140,10,60,20", "brown"))
+	azu := lineOf("azu-1",
+		word("12,11,48,20", "The"),
+		word("71,9,58,21", "qu1ck"),
+		word("141,10,59,20", "brown"),
+		word("500,10,40,20", "fox"))
+	gcp := lineOf("gcp-1",
+		word("9,10,52,19", "Tho"),
+		word("69,10,62,20", "quick"),
+		word("139,11,61,20", "brown"))
+
+	merged, err := Merge([]Detection{aws, azu, gcp}, MergeVote)
+	assert(t, err == nil, "merge error")
+	assert(t, merged.AlgoID == "merged-aws+azu+gcp", "merged algoid")
+	assert(t, merged.Plaintext() == "The quick brown", "majority vote and quorum")
+
+	merged, err = Merge([]Detection{aws, azu, gcp}, MergeUnion)
+	assert(t, err == nil, "union error")
+	assert(t, merged.Plaintext() == "The quick brown fox", "union keeps every word")
+
+	merged, err = Merge([]Detection{azu, gcp, aws}, MergeBest)
+	assert(t, err == nil, "best error")
+	assert(t, merged.Plaintext() == "The quick brown", "best picks aws")
+	assert(t, merged.AlgoID == "aws-1", "best keeps its algoid: "+merged.AlgoID)
+
+	// Two detections must both find a word
+	merged, err = Merge([]Detection{aws, azu}, MergeVote)
+	assert(t, err == nil, "merge two error")
+	assert(t, merged.Plaintext() == "The quick brown", "two detections drop fox")
+}
+
+func TestMergeOrphans(t *testing.T) {
+	a := lineOf("aws-1",
+		word("10,10,50,20", "one"),
+		word("70,10,50,20", "two"),
+		word("130,10,50,20", "three"))
+	b := lineOf("azu-1", word("10,10,50,20", "one"), word("10,500,50,20", "below"))
+	c := lineOf("gcp-1",
+		word("70,10,50,20", "two"),
+		word("130,10,50,20", "three"),
+		word("10,500,50,20", "below"))
+
+	// Every word is found twice. "below" is not in the reference
+	merged, err := Merge([]Detection{a, b, c}, MergeVote)
+	assert(t, err == nil, "merge error")
+	assert(t, merged.Plaintext() == "one two three\nbelow", "orphan words are kept")
+	nb, nl, nw := merged.CountBLW()
+	assert(t, nb == 2 && nl == 2 && nw == 4, "orphans get their own block")
+}
+
+func TestMergeEmpty(t *testing.T) {
+	_, err := Merge(nil, MergeVote)
+	assert(t, err != nil, "empty merge")
+}
+
+func TestParseMergeStrategy(t *testing.T) {
+	s, err := ParseMergeStrategy("union")
+	assert(t, err == nil && s == MergeUnion, "parse union")
+	_, err = ParseMergeStrategy("median")
+	assert(t, err != nil, "parse unknown")
+}
+
+func TestMergeWeightedVote(t *testing.T) {
+	w := func(text string, conf float64) Word {
+		return Word{Bounds: "10,10,50,20", Text: text, Conf: conf}
+	}
+	a := lineOf("aws-1", w("rn", 0.3))
+	b := lineOf("azureread-1", w("m", 0.9))
+	c := lineOf("gcp-1", w("rn", 0.4))
+
+	// 0.9 for "m" outweighs 0.3 + 0.4 for "rn"
+	merged, err := Merge([]Detection{a, b, c}, MergeVote)
+	assert(t, err == nil, "merge error")
+	assert(t, merged.Plaintext() == "m", "confidence weighted vote")
+	assert(t, merged.Pages[0].Blocks[0].Lines[0].Words[0].Conf == 0.9, "merged confidence")
+
+	// Without confidences, the majority wins
+	b = lineOf("azu-1", word("10,10,50,20", "m"))
+	a, c = lineOf("aws-1", word("10,10,50,20", "rn")), lineOf("gcp-1", word("10,10,50,20", "rn"))
+	merged, _ = Merge([]Detection{a, b, c}, MergeVote)
+	assert(t, merged.Plaintext() == "rn", "unweighted vote")
+}
@@ -1,6 +1,7 @@
 package ocr
 
 import (
+	"context"
 	"time"
 )
 
@@ -11,10 +12,13 @@ type Result struct {
 	Duration int64  `json:"milliseconds"`
 	Date     string `json:"date"`
 	Raw      []byte `json:"raw"`
+	Attempts int    `json:"attempts,omitempty"` // Requests made, including retries
 }
 
 type Client interface {
 	Run(image []byte) (*Result, error)
+	// Same as Run. Aborts the request when ctx is cancelled or its deadline passes
+	RunContext(ctx context.Context, image []byte) (*Result, error)
 	// Converts lossless Result format to unified Detection format
 	ResultToDetection(result *Result, width, height int) (*Detection, error)
 }
@@ -0,0 +1,173 @@
+package ocr
+
+import (
+	"sort"
+)
+
+// Extent of a word along one axis
+type interval struct {
+	lo, hi int
+	i      int // Index of the word
+}
+
+// Cuts the words at the widest gap in their projection onto one axis if it
+// is at least minGap. Returns the words before and after the cut
+func cutWidest(ivs []interval, minGap int) ([]int, []int, bool) {
+	sort.SliceStable(ivs, func(i, j int) bool { return ivs[i].lo < ivs[j].lo })
+	cut, widest := 0, 0
+	reach := ivs[0].hi
+	for k, iv := range ivs[1:] {
+		if gap := iv.lo - reach; gap >= minGap && gap > widest {
+			cut, widest = k+1, gap
+		}
+		reach = max(reach, iv.hi)
+	}
+	if cut == 0 {
+		return nil, nil, false
+	}
+	before, after := make([]int, 0, cut), make([]int, 0, len(ivs)-cut)
+	for k, iv := range ivs {
+		if k < cut {
+			before = append(before, iv.i)
+		} else {
+			after = append(after, iv.i)
+		}
+	}
+	return before, after, true
+}
+
+// Orders the words by recursive XY-cut. A region is cut at its widest
+// vertical gap if it is at least minGap, which separates columns left to
+// right. Otherwise at its widest horizontal gap if it is at least minGap,
+// which separates sections top to bottom. Words of a region that cannot be
+// cut are ordered top to bottom, then left to right
+func xyCut(ws []mWord, is []int, minGap int) []int {
+	if len(is) <= 1 {
+		return is
+	}
+	xs, ys := make([]interval, len(is)), make([]interval, len(is))
+	for k, i := range is {
+		b := ws[i].b
+		xs[k] = interval{b.X, b.X + b.W, i}
+		ys[k] = interval{b.Y, b.Y + b.H, i}
+	}
+	before, after, ok := cutWidest(xs, minGap)
+	if !ok {
+		before, after, ok = cutWidest(ys, minGap)
+	}
+	if !ok {
+		leaf := append([]int(nil), is...)
+		sort.SliceStable(leaf, func(i, j int) bool {
+			bi, bj := ws[leaf[i]].b, ws[leaf[j]].b
+			return bi.Y < bj.Y || (bi.Y == bj.Y && bi.X < bj.X)
+		})
+		return leaf
+	}
+	return append(xyCut(ws, before, minGap), xyCut(ws, after, minGap)...)
+}
+
+// Returns the median word height or 1 if there are no words
+func medianHeight(ws []mWord) int {
+	if len(ws) == 0 {
+		return 1
+	}
+	hs := make([]int, len(ws))
+	for i, w := range ws {
+		hs[i] = w.b.H
+	}
+	sort.Ints(hs)
+	return max(hs[len(hs)/2], 1)
+}
+
+// Returns a copy of the page with its lines in reading order. Lines keep
+// their words. Consecutive lines of the same block stay in one block, so
+// blocks that span columns are split. Split blocks take the union of the
+// bounds of their lines
+func (p *Page) readingOrder() (Page, error) {
+	ws, err := p.flattenFrom(0)
+	if err != nil {
+		return Page{}, err
+	}
+	is := make([]int, len(ws))
+	for i := range is {
+		is[i] = i
+	}
+	// Gutters and breaks between sections are wider than the space between
+	// words or lines
+	order := xyCut(ws, is, medianHeight(ws))
+
+	// Each line ranks as its earliest word. Lines and blocks without words
+	// go last
+	rank := make(map[[2]int]int)
+	for r, i := range order {
+		key := [2]int{ws[i].bi, ws[i].li}
+		if _, ok := rank[key]; !ok {
+			rank[key] = r
+		}
+	}
+	type lineRef struct{ bi, li, rank int }
+	var refs []lineRef
+	for bi, b := range p.Blocks {
+		if len(b.Lines) == 0 {
+			refs = append(refs, lineRef{bi, -1, len(order)})
+		}
+		for li := range b.Lines {
+			r, ok := rank[[2]int{bi, li}]
+			if !ok {
+				r = len(order)
+			}
+			refs = append(refs, lineRef{bi, li, r})
+		}
+	}
+	sort.SliceStable(refs, func(i, j int) bool { return refs[i].rank < refs[j].rank })
+
+	ordered := *p
+	ordered.Blocks = make([]Block, 0, len(p.Blocks))
+	src := make([]int, 0, len(p.Blocks)) // Source block of each ordered block
+	for k, ref := range refs {
+		if k == 0 || ref.bi != refs[k-1].bi || ref.li < 0 {
+			ordered.Blocks = append(ordered.Blocks, Block{Lines: []Line{}})
+			src = append(src, ref.bi)
+		}
+		if ref.li >= 0 {
+			b := &ordered.Blocks[len(ordered.Blocks)-1]
+			b.Lines = append(b.Lines, p.Blocks[ref.bi].Lines[ref.li])
+		}
+	}
+	for i := range ordered.Blocks {
+		b, orig := &ordered.Blocks[i], p.Blocks[src[i]]
+		if len(b.Lines) == len(orig.Lines) {
+			b.Bounds, b.Poly = orig.Bounds, orig.Poly
+			continue
+		}
+		bounds, err := DecodeBounds(b.Lines[0].Bounds)
+		if err != nil {
+			return Page{}, err
+		}
+		for _, l := range b.Lines[1:] {
+			lb, err := DecodeBounds(l.Bounds)
+			if err != nil {
+				return Page{}, err
+			}
+			bounds = union(bounds, lb)
+		}
+		b.Bounds = encodeBounds(bounds)
+	}
+	return ordered, nil
+}
+
+// Returns a copy of the detection with the lines of each page in reading
+// order, such as down each column of a two column page in turn. Columns are
+// found by XY-cut over the word boxes
+func ReadingOrder(d *Detection) (*Detection, error) {
+	ordered := *d
+	ordered.Pages = make([]Page, 0, len(d.Pages))
+	for i := range d.Pages {
+		p, err := d.Pages[i].readingOrder()
+		if err != nil {
+			return nil, err
+		}
+		ordered.Pages = append(ordered.Pages, p)
+	}
+	return &ordered, nil
+}
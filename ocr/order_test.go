@@ -0,0 +1,63 @@
+package ocr
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Returns a line of one word per text, each 40 wide and 20 high
+func testLine(x, y int, texts ...string) Line {
+	var words []Word
+	for i, t := range texts {
+		words = append(words, Word{Bounds: encodeRawBounds(x+i*50, y, 40, 20), Text: t})
+	}
+	return Line{Bounds: encodeRawBounds(x, y, len(texts)*50-10, 20), Words: words}
+}
+
+func TestReadingOrder(t *testing.T) {
+	// A title over two columns whose rows the provider reads across
+	title := testLine(100, 10, "Two", "columns")
+	page := Page{Width: 400, Height: 200, Blocks: []Block{{Bounds: "100,10,90,20", Lines: []Line{title}}}}
+	for row := 0; row < 3; row++ {
+		y := 60 + row*30
+		left := testLine(10, y, fmt.Sprintf("l%da", row), fmt.Sprintf("l%db", row))
+		right := testLine(210, y, fmt.Sprintf("r%da", row), fmt.Sprintf("r%db", row))
+		page.Blocks = append(page.Blocks, Block{Bounds: encodeRawBounds(10, y, 290, 20), Lines: []Line{left, right}})
+	}
+	d := &Detection{AlgoID: "test", Pages: []Page{page}}
+	assert(t, strings.HasPrefix(d.Plaintext(), "Two columns\nl0a l0b\nr0a r0b"), "provider order")
+
+	ordered, err := ReadingOrder(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Two columns\nl0a l0b\nl1a l1b\nl2a l2b\nr0a r0b\nr1a r1b\nr2a r2b"
+	if got := ordered.Plaintext(); got != want {
+		t.Fatalf("Expected %q. Received: %q", want, got)
+	}
+	blocks := ordered.Pages[0].Blocks
+	assert(t, len(blocks) == 7, "blocks split across columns")
+	assert(t, blocks[0].Bounds == "100,10,90,20", "whole block keeps its bounds")
+	assert(t, blocks[1].Bounds == "10,60,90,20", "split block bounds: "+blocks[1].Bounds)
+	assert(t, d.Pages[0].Blocks[1].Lines[1].Words[0].Text == "r0a", "original unchanged")
+}
+
+func TestReadingOrderSingleColumn(t *testing.T) {
+	d := testDetection()
+	ordered, err := ReadingOrder(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, ordered.Plaintext() == d.Plaintext(), "single column unchanged")
+
+	// Lines out of order within a block are sorted top to bottom
+	lines := []Line{testLine(10, 40, "second"), testLine(10, 10, "first")}
+	d = &Detection{Pages: []Page{{Blocks: []Block{{Bounds: "10,10,40,50", Lines: lines}, {Lines: []Line{}}}}}}
+	ordered, err = ReadingOrder(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, ordered.Plaintext() == "first\nsecond\n", "lines sorted: "+ordered.Plaintext())
+	assert(t, len(ordered.Pages[0].Blocks) == 2, "empty block kept")
+}
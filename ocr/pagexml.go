@@ -0,0 +1,265 @@
+package ocr
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// PAGE-XML (www.primaresearch.org/schema/PAGE/) as exported by tools such as
+// Transkribus, with only what Detection keeps
+type pageDoc struct {
+	XMLName xml.Name   `xml:"PcGts"`
+	Creator string     `xml:"Metadata>Creator"`
+	Pages   []pagePage `xml:"Page"`
+}
+
+type pagePage struct {
+	Width   int               `xml:"imageWidth,attr"`
+	Height  int               `xml:"imageHeight,attr"`
+	Order   []pageRegionRef   `xml:"ReadingOrder>OrderedGroup>RegionRefIndexed"`
+	Regions []pageRegion      `xml:"TextRegion"`
+	Tables  []pageTableRegion `xml:"TableRegion"`
+}
+
+type pageRegionRef struct {
+	Index  int    `xml:"index,attr"`
+	Region string `xml:"regionRef,attr"`
+}
+
+type pageTableRegion struct {
+	Cells []pageRegion `xml:"TableCell"`
+}
+
+// A TextRegion or TableCell
+type pageRegion struct {
+	ID      string       `xml:"id,attr"`
+	Coords  pageCoords   `xml:"Coords"`
+	Lines   []pageLine   `xml:"TextLine"`
+	Regions []pageRegion `xml:"TextRegion"`
+}
+
+type pageLine struct {
+	Coords pageCoords    `xml:"Coords"`
+	Words  []pageWord    `xml:"Word"`
+	Text   pageTextEquiv `xml:"TextEquiv"`
+}
+
+type pageWord struct {
+	Coords pageCoords    `xml:"Coords"`
+	Text   pageTextEquiv `xml:"TextEquiv"`
+}
+
+type pageTextEquiv struct {
+	Conf    float64 `xml:"conf,attr"`
+	Unicode string  `xml:"Unicode"`
+}
+
+// Points are "x,y x,y ..." since PAGE 2013 and Point elements before
+type pageCoords struct {
+	Points    string      `xml:"points,attr"`
+	OldPoints []pagePoint `xml:"Point"`
+}
+
+type pagePoint struct {
+	X float64 `xml:"x,attr"`
+	Y float64 `xml:"y,attr"`
+}
+
+func (c pageCoords) points() ([]image.Point, error) {
+	round := func(f float64) int { return int(math.Round(f)) }
+	var ps []image.Point
+	for _, p := range c.OldPoints {
+		ps = append(ps, image.Point{round(p.X), round(p.Y)})
+	}
+	for _, xy := range strings.Fields(c.Points) {
+		fields := strings.Split(xy, ",")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("Expected points as x,y. Found: %s", xy)
+		}
+		x, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, err
+		}
+		y, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, image.Point{round(x), round(y)})
+	}
+	return ps, nil
+}
+
+// Returns the bounds of the polygon and the polygon itself if it has more
+// than two points. The bounds of no points are the empty string
+func (c pageCoords) boundsPoly() (string, string, error) {
+	ps, err := c.points()
+	if err != nil || len(ps) == 0 {
+		return "", "", err
+	}
+	poly := ""
+	if len(ps) > 2 {
+		poly = encodePoly(ps)
+	}
+	return pointsToBounds(ps), poly, nil
+}
+
+// Returns the union of the bounds
+func unionBounds(bounds []string) (string, error) {
+	var ps []image.Point
+	for _, s := range bounds {
+		b, err := DecodeBounds(s)
+		if err != nil {
+			return "", err
+		}
+		ps = append(ps, image.Point{b.X, b.Y}, image.Point{b.X + b.W, b.Y + b.H})
+	}
+	return pointsToBounds(ps), nil
+}
+
+// Splits a line without positioned words into words, estimating their bounds
+// by dividing the line's width by their share of its characters
+func splitLine(bounds, text string, conf float64) ([]Word, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return []Word{}, nil
+	}
+	b, err := DecodeBounds(bounds)
+	if err != nil {
+		return nil, err
+	}
+	n := utf8.RuneCountInString(strings.Join(fields, " "))
+	words := make([]Word, 0, len(fields))
+	at := 0 // Characters before the word
+	for _, f := range fields {
+		m := utf8.RuneCountInString(f)
+		x0 := b.X + b.W*at/n
+		x1 := b.X + b.W*(at+m)/n
+		words = append(words, Word{Bounds: encodeRawBounds(x0, b.Y, x1-x0, b.H), Text: f, Conf: conf})
+		at += m + 1
+	}
+	return words, nil
+}
+
+func (l pageLine) line() (Line, error) {
+	bounds, poly, err := l.Coords.boundsPoly()
+	if err != nil {
+		return Line{}, err
+	}
+	words := make([]Word, 0, len(l.Words))
+	for _, pw := range l.Words {
+		wb, wp, err := pw.Coords.boundsPoly()
+		if err != nil {
+			return Line{}, err
+		}
+		if text := strings.TrimSpace(pw.Text.Unicode); text != "" && wb != "" {
+			words = append(words, Word{Bounds: wb, Text: text, Conf: pw.Text.Conf, Poly: wp})
+		}
+	}
+	// Also when every Word lacks coordinates, as the line's text still has them
+	if len(words) == 0 && bounds != "" {
+		if words, err = splitLine(bounds, l.Text.Unicode, l.Text.Conf); err != nil {
+			return Line{}, err
+		}
+	}
+	conf := l.Text.Conf
+	if conf == 0 {
+		conf = meanConf(words)
+	}
+	return Line{Bounds: bounds, Words: words, Conf: conf, Poly: poly}, nil
+}
+
+// Returns the region and its nested regions as blocks. Regions without
+// coordinates take the bounds of their lines
+func (r pageRegion) blocks() ([]Block, error) {
+	bounds, poly, err := r.Coords.boundsPoly()
+	if err != nil {
+		return nil, fmt.Errorf("region %s: %v", r.ID, err)
+	}
+	lines := make([]Line, 0, len(r.Lines))
+	var lineBounds []string
+	for _, pl := range r.Lines {
+		line, err := pl.line()
+		if err != nil {
+			return nil, fmt.Errorf("region %s: %v", r.ID, err)
+		}
+		if line.Bounds == "" || len(line.Words) == 0 {
+			continue // No position or no text
+		}
+		lines = append(lines, line)
+		lineBounds = append(lineBounds, line.Bounds)
+	}
+	var blocks []Block
+	if len(lines) > 0 {
+		if bounds == "" {
+			if bounds, err = unionBounds(lineBounds); err != nil {
+				return nil, err
+			}
+		}
+		blocks = append(blocks, Block{Bounds: bounds, Lines: lines, Poly: poly})
+	}
+	for _, nested := range r.Regions {
+		nb, err := nested.blocks()
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, nb...)
+	}
+	return blocks, nil
+}
+
+// Returns the regions in reading order. Regions missing from the reading
+// order follow in document order
+func (p pagePage) orderedRegions() []pageRegion {
+	regions := append([]pageRegion(nil), p.Regions...)
+	for _, t := range p.Tables {
+		regions = append(regions, t.Cells...)
+	}
+	index := make(map[string]int)
+	for _, ref := range p.Order {
+		index[ref.Region] = ref.Index
+	}
+	sort.SliceStable(regions, func(i, j int) bool {
+		a, aok := index[regions[i].ID]
+		b, bok := index[regions[j].ID]
+		if aok && bok {
+			return a < b
+		}
+		return aok && !bok
+	})
+	return regions
+}
+
+// Decodes PAGE-XML, such as hand-corrected ground truth, into a Detection.
+// Regions become blocks, text lines lines and words words. Polygons are
+// kept as Poly and reduced to their bounds. Lines without Word elements are
+// split into words with estimated bounds. Words without coordinates are
+// skipped
+func DecodePAGE(data []byte) (*Detection, error) {
+	var doc pageDoc
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	algoID := "pagexml"
+	if f := strings.Fields(doc.Creator); len(f) > 0 {
+		algoID = sanitizeString("pagexml-" + f[0])
+	}
+	d := &Detection{AlgoID: algoID}
+	for _, pp := range doc.Pages {
+		page := Page{Width: pp.Width, Height: pp.Height, Blocks: []Block{}}
+		for _, r := range pp.orderedRegions() {
+			blocks, err := r.blocks()
+			if err != nil {
+				return nil, err
+			}
+			page.Blocks = append(page.Blocks, blocks...)
+		}
+		d.Pages = append(d.Pages, page)
+	}
+	return d, nil
+}
@@ -0,0 +1,100 @@
+package ocr
+
+import (
+	"testing"
+)
+
+const testPAGE = `<?xml version="1.0" encoding="UTF-8"?>
+<PcGts xmlns="http://schema.primaresearch.org/PAGE/gts/pagecontent/2019-07-15">
+  <Metadata><Creator>Transkribus 1.2</Creator></Metadata>
+  <Page imageFilename="page.png" imageWidth="300" imageHeight="200">
+    <ReadingOrder><OrderedGroup id="ro1">
+      <RegionRefIndexed index="1" regionRef="r1"/>
+      <RegionRefIndexed index="0" regionRef="r2"/>
+    </OrderedGroup></ReadingOrder>
+    <TextRegion id="r1">
+      <Coords points="10,100 110,95 112,130 10,130"/>
+      <TextLine id="l1">
+        <Coords points="10,100 110,100 110,120 10,120"/>
+        <TextEquiv><Unicode>ab cd</Unicode></TextEquiv>
+      </TextLine>
+    </TextRegion>
+    <TextRegion id="r2">
+      <TextLine id="l2">
+        <Coords><Point x="10" y="10"/><Point x="60.6" y="10"/><Point x="60.6" y="30"/><Point x="10" y="30"/></Coords>
+        <Word id="w1"><Coords points="10,10 30,10 30,30 10,30"/><TextEquiv conf="0.9"><Unicode>Ground</Unicode></TextEquiv></Word>
+        <Word id="w2"><Coords points="35,10 61,10 61,30 35,30"/><TextEquiv conf="0.7"><Unicode>truth</Unicode></TextEquiv></Word>
+      </TextLine>
+    </TextRegion>
+    <TextRegion id="r3">
+      <Coords points="0,0 1,1 2,0"/>
+    </TextRegion>
+  </Page>
+</PcGts>`
+
+func TestDecodePAGE(t *testing.T) {
+	d, err := DecodeXML([]byte(testPAGE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, d.AlgoID == "pagexml-transkribus", "page algo id: "+d.AlgoID)
+	assert(t, len(d.Pages) == 1 && d.Pages[0].Width == 300 && d.Pages[0].Height == 200, "page size")
+	blocks := d.Pages[0].Blocks
+	if len(blocks) != 2 {
+		t.Fatalf("Expected 2 blocks without the empty region. Received: %+v", blocks)
+	}
+	assert(t, d.Plaintext() == "Ground truth\nab cd", "page reading order: "+d.Plaintext())
+
+	// Region from the union of its lines, legacy points rounded
+	assert(t, blocks[0].Bounds == "10,10,51,20" && blocks[0].Poly == "", "region bounds from lines: "+blocks[0].Bounds)
+	words := blocks[0].Lines[0].Words
+	assert(t, words[1].Bounds == "35,10,26,20" && words[1].Conf == 0.7, "word bounds: "+words[1].Bounds)
+	assert(t, blocks[0].Lines[0].Conf == 0.8, "line conf from words")
+
+	// Polygon reduced to its bounds
+	assert(t, blocks[1].Bounds == "10,95,102,35", "polygon bounds: "+blocks[1].Bounds)
+	assert(t, blocks[1].Poly == "10,100,110,95,112,130,10,130", "polygon kept: "+blocks[1].Poly)
+
+	// Words estimated from the line text
+	words = blocks[1].Lines[0].Words
+	if len(words) != 2 {
+		t.Fatalf("Expected 2 words split from the line. Received: %+v", words)
+	}
+	assert(t, words[0].Bounds == "10,100,40,20" && words[1].Bounds == "70,100,40,20", "split words: "+words[0].Bounds+" "+words[1].Bounds)
+}
+
+func TestDecodePAGEInvalid(t *testing.T) {
+	doc := `<PcGts><Page><TextRegion id="r1"><TextLine><Coords points="1,2,3"/></TextLine></TextRegion></Page></PcGts>`
+	_, err := DecodeXML([]byte(doc))
+	assert(t, err != nil, "invalid points")
+}
+
+func TestDecodePAGEMissing(t *testing.T) {
+	doc := `<PcGts><Metadata><Creator> </Creator></Metadata><Page imageWidth="10" imageHeight="10">
+  <TextRegion id="r1"><TextLine><Coords points="0,0 9,0 9,5 0,5"/>
+    <Word><TextEquiv><Unicode>lost</Unicode></TextEquiv></Word>
+    <Word><Coords points="0,0 4,0 4,5 0,5"/><TextEquiv><Unicode>kept</Unicode></TextEquiv></Word>
+  </TextLine></TextRegion></Page></PcGts>`
+	d, err := DecodeXML([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, d.AlgoID == "pagexml", "blank creator: "+d.AlgoID)
+	assert(t, d.Plaintext() == "kept", "word without coords skipped: "+d.Plaintext())
+}
+
+func TestDecodePAGEWordsWithoutCoords(t *testing.T) {
+	doc := `<PcGts><Page imageWidth="10" imageHeight="10">
+  <TextRegion id="r1"><TextLine><Coords points="0,0 9,0 9,5 0,5"/>
+    <Word><TextEquiv><Unicode>lo</Unicode></TextEquiv></Word>
+    <Word><TextEquiv><Unicode>st</Unicode></TextEquiv></Word>
+    <TextEquiv><Unicode>not lost</Unicode></TextEquiv>
+  </TextLine></TextRegion></Page></PcGts>`
+	d, err := DecodeXML([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, d.Plaintext() == "not lost", "line text when no word has coords: "+d.Plaintext())
+	words := d.Pages[0].Blocks[0].Lines[0].Words
+	assert(t, len(words) == 2 && words[0].Bounds == "0,0,3,5", "split words: "+words[0].Bounds)
+}
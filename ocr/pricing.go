@@ -0,0 +1,47 @@
+package ocr
+
+// Tier prices each page up to UpTo pages (inclusive) at PerPage dollars. An
+// UpTo of 0 has no limit
+type Tier struct {
+	UpTo    int
+	PerPage float64
+}
+
+// Pricing lists the tiers of a provider by increasing UpTo. Pages past the
+// last tier are priced at the last tier. No tiers is free
+type Pricing []Tier
+
+// Returns the cost in dollars of the given number of pages, assuming none
+// were billed before them in the billing period
+func (p Pricing) Cost(pages int) float64 {
+	return p.After(0, pages)
+}
+
+// Returns the cost in dollars of pages billed after the first billed ones
+// of the billing period
+func (p Pricing) After(billed, pages int) float64 {
+	cost := 0.0
+	lo := 0 // Pages below the current tier
+	for i, t := range p {
+		hi := t.UpTo
+		if hi == 0 || i == len(p)-1 {
+			hi = billed + pages // Last tier takes the rest
+		}
+		// Pages of [billed, billed+pages) within [lo, hi)
+		from, to := billed, billed+pages
+		if from < lo {
+			from = lo
+		}
+		if to > hi {
+			to = hi
+		}
+		if to > from {
+			cost += float64(to-from) * t.PerPage
+		}
+		if hi >= billed+pages {
+			break
+		}
+		lo = hi
+	}
+	return cost
+}
@@ -0,0 +1,28 @@
+package ocr
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestPricing(t *testing.T) {
+	p := Pricing{{10, 0}, {100, 0.01}, {0, 0.001}}
+	cases := []struct {
+		billed, pages int
+		want          float64
+	}{
+		{0, 5, 0},
+		{0, 20, 0.1},
+		{5, 10, 0.05},
+		{0, 200, 0.9 + 0.1},
+		{150, 10, 0.01},
+		{99, 2, 0.011},
+	}
+	for _, c := range cases {
+		got := p.After(c.billed, c.pages)
+		assert(t, math.Abs(got-c.want) < 1e-9, fmt.Sprintf("%d pages after %d: %v", c.pages, c.billed, got))
+	}
+	assert(t, Pricing(nil).Cost(1000) == 0, "free")
+	assert(t, Pricing{{5, 0.5}}.Cost(10) == 5, "last tier takes the rest")
+}
@@ -0,0 +1,99 @@
+package ocr
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Creates a client that reads its keys from the credentials directory
+type Factory func(credentialsPath string) Client
+
+// Provider describes an OCR service. Providers register themselves so that
+// the command line can offer them without knowing about them in advance
+type Provider struct {
+	Name    string      // Name of the command line flag, i.e. aws
+	Key     string      // Short key used in file names, i.e. aws for *.aws.json
+	Service string      // Result.Service reported by the client, i.e. AWS
+	AlgoID  string      // AlgoID prefix of its detections, i.e. aws for aws-1_0
+	Usage   string      // Help text of the command line flag
+	New     Factory     // Creates a client for the provider
+	Color   color.Color // Color used to annotate the provider's detections
+	Pricing Pricing     // Price per page. Free if empty
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Provider)
+)
+
+// Makes a provider available by name. Panics if the name, key, service or
+// AlgoID prefix is empty or already registered
+func Register(p Provider) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if p.Name == "" || p.Key == "" || p.Service == "" || p.AlgoID == "" || p.New == nil {
+		panic(fmt.Sprintf("ocr: Register provider %q is missing fields", p.Name))
+	}
+	for _, q := range registry {
+		if q.Name == p.Name || q.Key == p.Key || q.Service == p.Service || strings.EqualFold(q.AlgoID, p.AlgoID) {
+			panic(fmt.Sprintf("ocr: Register called twice for provider %q", p.Name))
+		}
+	}
+	if p.Color == nil {
+		p.Color = color.Black
+	}
+	registry[p.Name] = p
+}
+
+// Returns the registered providers sorted by name
+func Providers() []Provider {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	ps := make([]Provider, 0, len(registry))
+	for _, p := range registry {
+		ps = append(ps, p)
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		return ps[i].Name < ps[j].Name
+	})
+	return ps
+}
+
+// Returns the first provider, by name, that matches
+func lookup(match func(p Provider) bool) (Provider, bool) {
+	for _, p := range Providers() {
+		if match(p) {
+			return p, true
+		}
+	}
+	return Provider{}, false
+}
+
+// Returns the provider with the given short key, i.e. azuR
+func ProviderByKey(key string) (Provider, bool) {
+	return lookup(func(p Provider) bool { return p.Key == key })
+}
+
+// Returns the provider whose clients report the given Result.Service
+func ProviderByService(service string) (Provider, bool) {
+	return lookup(func(p Provider) bool { return p.Service == service })
+}
+
+// Returns the provider that produced a detection with the given AlgoID.
+// AlgoIDs start with the AlgoID prefix of the provider and a dash. Case is
+// ignored
+func ProviderByAlgoID(algoID string) (Provider, bool) {
+	prefix := strings.SplitN(algoID, "-", 2)[0]
+	return lookup(func(p Provider) bool { return strings.EqualFold(p.AlgoID, prefix) })
+}
+
+// Returns the annotation color for the AlgoID or black if it is unknown
+func AlgoIDColor(algoID string) color.Color {
+	if p, ok := ProviderByAlgoID(algoID); ok {
+		return p.Color
+	}
+	return color.Black
+}
@@ -0,0 +1,31 @@
+package ocr
+
+import (
+	"testing"
+)
+
+func TestProviderByAlgoID(t *testing.T) {
+	for algoID, name := range map[string]string{
+		"aws-1_0":               "aws",
+		"azu-vision_v3_1_ocr":   "azure",
+		"azureread-2020-05-01":  "azureR",
+		"gcp-v1":                "gcp",
+		"loc-4_1_1":             "local",
+		"AWS-upper-case-prefix": "aws",
+	} {
+		p, ok := ProviderByAlgoID(algoID)
+		assert(t, ok && p.Name == name, "algoid "+algoID)
+	}
+	_, ok := ProviderByAlgoID("merged-aws+gcp")
+	assert(t, !ok, "merged algoid has no provider")
+	_, ok = ProviderByAlgoID("azur-1")
+	assert(t, !ok, "prefixes match exactly")
+}
+
+func TestRegisterTwice(t *testing.T) {
+	defer func() {
+		assert(t, recover() != nil, "duplicate register panics")
+	}()
+	p, _ := ProviderByKey("aws")
+	Register(p)
+}
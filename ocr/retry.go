@@ -0,0 +1,149 @@
+package ocr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StatusError is an unexpected HTTP status from a provider
+type StatusError struct {
+	Service    string
+	Code       int           // Received status code
+	Expected   int           // Expected status code
+	RetryAfter time.Duration // From the Retry-After header. 0 if missing
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: received status code %v (expected %v)", e.Service, e.Code, e.Expected)
+}
+
+// Returns a StatusError for the response
+func newStatusError(service string, response *http.Response, expected int) *StatusError {
+	return &StatusError{service, response.StatusCode, expected, parseRetryAfter(response.Header.Get("Retry-After"), time.Now())}
+}
+
+// Parses a Retry-After header given in seconds or as an HTTP date. Returns
+// 0 if it is missing or invalid
+func parseRetryAfter(s string, now time.Time) time.Duration {
+	if secs, err := strconv.Atoi(s); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(s); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// Returns whether err is transient: throttling (429), server errors (5xx)
+// and timeouts. Errors such as bad credentials or a bad image are permanent
+func Retryable(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return retryableStatus(se.Code)
+	}
+	var rf awserr.RequestFailure
+	if errors.As(err, &rf) && retryableStatus(rf.StatusCode()) {
+		return true
+	}
+	var ae awserr.Error
+	if errors.As(err, &ae) && request.IsErrorThrottle(ae) {
+		return true
+	}
+	var gs interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &gs) {
+		switch gs.GRPCStatus().Code() {
+		case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Aborted:
+			return true
+		}
+		return false
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// Returns how long the provider asked to wait before retrying, or 0
+func retryAfter(err error) time.Duration {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.RetryAfter
+	}
+	return 0
+}
+
+// RetryPolicy retries retryable errors with exponential backoff
+type RetryPolicy struct {
+	MaxAttempts int           // Including the first. 1 never retries
+	Base        time.Duration // Backoff before the first retry
+	Max         time.Duration // Largest backoff, unless Retry-After asks for more
+}
+
+// Used by clients without a RetryPolicy
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 4, Base: 500 * time.Millisecond, Max: 30 * time.Second}
+
+// Returns the policy, or DefaultRetryPolicy for the zero value
+func (p RetryPolicy) orDefault() RetryPolicy {
+	if p == (RetryPolicy{}) {
+		return DefaultRetryPolicy
+	}
+	return p
+}
+
+// Returns the backoff after the given failed attempt (1 for the first),
+// with jitter of up to half of it
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.Max
+	if attempt < 32 && p.Base<<uint(attempt-1) < p.Max {
+		d = p.Base << uint(attempt-1)
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// Calls attempt until it succeeds, fails permanently, runs out of attempts,
+// or ctx is done. Returns the number of attempts made
+func (p RetryPolicy) Do(ctx context.Context, attempt func() error) (int, error) {
+	p = p.orDefault()
+	for n := 1; ; n++ {
+		err := attempt()
+		if err == nil {
+			return n, nil
+		}
+		if n >= p.MaxAttempts || ctx.Err() != nil || !Retryable(err) {
+			if n > 1 {
+				return n, fmt.Errorf("%w (after %d attempts)", err, n)
+			}
+			return n, err
+		}
+		delay := p.backoff(n)
+		if after := retryAfter(err); after > delay {
+			delay = after
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return n, fmt.Errorf("%w (stopped retrying: %v)", err, ctx.Err())
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package ocr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var fastRetry = RetryPolicy{MaxAttempts: 3, Base: time.Millisecond, Max: time.Millisecond}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert(t, parseRetryAfter("7", now) == 7*time.Second, "retry after seconds")
+	assert(t, parseRetryAfter("Wed, 01 Jan 2020 00:00:30 GMT", now) == 30*time.Second, "retry after date")
+	assert(t, parseRetryAfter("", now) == 0 && parseRetryAfter("soon", now) == 0, "retry after invalid")
+}
+
+func TestRetryable(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{&StatusError{Code: 429}, true},
+		{fmt.Errorf("wrapped: %w", &StatusError{Code: 503}), true},
+		{&StatusError{Code: 401}, false},
+		{&StatusError{Code: 400}, false},
+		{status.Error(codes.ResourceExhausted, "quota"), true},
+		{status.Error(codes.InvalidArgument, "bad image"), false},
+		{context.DeadlineExceeded, true},
+		{errors.New("cannot read credentials"), false},
+	}
+	for i, c := range cases {
+		assert(t, Retryable(c.err) == c.want, fmt.Sprintf("retryable case %d: %v", i, c.err))
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 10, Base: time.Second, Max: 4 * time.Second}
+	for attempt, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		d := p.backoff(attempt + 1)
+		assert(t, d >= max/2 && d <= max, fmt.Sprintf("backoff %d: %v", attempt+1, d))
+	}
+}
+
+// Answers with the given statuses before replaying the fixture file
+func throttled(t *testing.T, name string, statuses ...int) *httptest.Server {
+	fixtures, err := LoadFixtures(path.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := NewReplayHandler(fixtures)
+	n := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if n < len(statuses) {
+			n++
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		replay.ServeHTTP(w, req)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAzureRetry(t *testing.T) {
+	server := throttled(t, "azure.json", 429, 503)
+	c := AzureClient{CredentialsPath: azureKeys(t, server), Retry: fastRetry}
+	result, err := c.Run([]byte("image"))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	assert(t, result.Attempts == 3 && result.FullText == "Hello world", "azure retried result")
+}
+
+func TestAzureRetryExhausted(t *testing.T) {
+	server := throttled(t, "azure.json", 429, 429, 429)
+	c := AzureClient{CredentialsPath: azureKeys(t, server), Retry: fastRetry}
+	_, err := c.Run([]byte("image"))
+	var se *StatusError
+	assert(t, errors.As(err, &se) && se.Code == 429, "azure status error")
+	assert(t, strings.Contains(err.Error(), "after 3 attempts"), "azure attempts in error")
+}
+
+func TestAzureReadPollThrottled(t *testing.T) {
+	fixtures, err := LoadFixtures(path.Join("testdata", "azure_read.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Throttle the first poll without resubmitting the image
+	throttle := Fixture{Method: "GET", Path: fixtures[1].Path, Status: 429}
+	fixtures = append(fixtures[:1], append([]Fixture{throttle}, fixtures[1:]...)...)
+	server := httptest.NewServer(NewReplayHandler(fixtures))
+	defer server.Close()
+	c := AzureReadClient{CredentialsPath: azureKeys(t, server), PollInterval: time.Millisecond, Retry: fastRetry}
+	result, err := c.Run([]byte("image"))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	assert(t, result.Attempts == 1 && result.FullText == "Hello world", "azure read throttled poll")
+}
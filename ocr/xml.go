@@ -0,0 +1,37 @@
+package ocr
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+)
+
+// Returns the local name of the root element of the XML document
+func xmlRoot(data []byte) (string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+// Decodes an XML document by its root element. Supports ALTO and PAGE-XML
+func DecodeXML(data []byte) (*Detection, error) {
+	root, err := xmlRoot(data)
+	if err != nil {
+		return nil, err
+	}
+	switch root {
+	case "alto":
+		return DecodeALTO(data)
+	case "PcGts":
+		return DecodePAGE(data)
+	default:
+		return nil, fmt.Errorf("Expected an alto or PcGts root element instead of: %q", root)
+	}
+}
@@ -0,0 +1,242 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Returns the stream's filters and their parameters, which may each be a
+// single value or an array
+func (r *Reader) filters(s *stream) ([]name, []dict) {
+	var names []name
+	var parms []dict
+	switch f := r.resolve(s.hdr["Filter"]).(type) {
+	case name:
+		names = []name{f}
+	case []object:
+		for _, o := range f {
+			if n, ok := r.resolve(o).(name); ok {
+				names = append(names, n)
+			}
+		}
+	}
+	switch p := r.resolve(s.hdr["DecodeParms"]).(type) {
+	case dict:
+		parms = []dict{p}
+	case []object:
+		for _, o := range p {
+			d, _ := r.resolve(o).(dict)
+			parms = append(parms, d)
+		}
+	}
+	for len(parms) < len(names) {
+		parms = append(parms, nil)
+	}
+	return names, parms
+}
+
+// Image formats that are left encoded for the image decoder
+var imageFilters = map[name]bool{
+	"DCTDecode": true, "DCT": true,
+	"JPXDecode": true, "CCITTFaxDecode": true, "CCF": true, "JBIG2Decode": true,
+}
+
+// Decodes the stream fully
+func (r *Reader) decode(s *stream) ([]byte, error) {
+	data, f, err := r.decodeImage(s)
+	if err == nil && f != "" {
+		err = fmt.Errorf("Unsupported filter %s", f)
+	}
+	return data, err
+}
+
+// Decodes the stream up to an image filter. Returns the data and that filter,
+// or "" if the data is fully decoded
+func (r *Reader) decodeImage(s *stream) ([]byte, name, error) {
+	data := s.raw
+	names, parms := r.filters(s)
+	for i, f := range names {
+		if imageFilters[f] {
+			if i != len(names)-1 {
+				return nil, "", fmt.Errorf("Unsupported filter after %s", f)
+			}
+			return data, f, nil
+		}
+		var err error
+		switch f {
+		case "FlateDecode", "Fl":
+			data, err = inflate(data)
+			if err == nil {
+				data, err = r.unpredict(data, parms[i])
+			}
+		case "ASCIIHexDecode", "AHx":
+			data = asciiHex(data)
+		case "ASCII85Decode", "A85":
+			data, err = ascii85Decode(data)
+		case "RunLengthDecode", "RL":
+			data = runLength(data)
+		default:
+			err = fmt.Errorf("Unsupported filter %s", f)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return data, "", nil
+}
+
+// Inflates zlib data, keeping what was read before any corruption
+func inflate(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("FlateDecode: %v", err)
+	}
+	defer zr.Close()
+	out, err := ioutil.ReadAll(zr)
+	if err != nil && (len(out) == 0 || err != io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("FlateDecode: %v", err)
+	}
+	return out, nil
+}
+
+// Undoes a PNG or TIFF predictor
+func (r *Reader) unpredict(data []byte, parms dict) ([]byte, error) {
+	predictor, _ := r.resolve(parms["Predictor"]).(int)
+	if predictor <= 1 {
+		return data, nil
+	}
+	colors, bpc, columns := 1, 8, 1
+	if v, ok := r.resolve(parms["Colors"]).(int); ok && v > 0 {
+		colors = v
+	}
+	if v, ok := r.resolve(parms["BitsPerComponent"]).(int); ok && v > 0 {
+		bpc = v
+	}
+	if v, ok := r.resolve(parms["Columns"]).(int); ok && v > 0 {
+		columns = v
+	}
+	bpp := (colors*bpc + 7) / 8
+	stride := (colors*bpc*columns + 7) / 8
+	if predictor == 2 {
+		if bpc != 8 {
+			return nil, fmt.Errorf("Unsupported TIFF predictor of %d bits", bpc)
+		}
+		for row := 0; row+stride <= len(data); row += stride {
+			for i := row + bpp; i < row+stride; i++ {
+				data[i] += data[i-bpp]
+			}
+		}
+		return data, nil
+	}
+	out := make([]byte, 0, len(data)/(stride+1)*stride)
+	prev := make([]byte, stride)
+	for len(data) > 0 {
+		kind := data[0]
+		n := stride
+		if len(data)-1 < n {
+			n = len(data) - 1
+		}
+		cur := make([]byte, stride)
+		copy(cur, data[1:1+n])
+		data = data[1+n:]
+		for i := range cur {
+			var a, c byte
+			if i >= bpp {
+				a, c = cur[i-bpp], prev[i-bpp]
+			}
+			b := prev[i]
+			switch kind {
+			case 1:
+				cur[i] += a
+			case 2:
+				cur[i] += b
+			case 3:
+				cur[i] += byte((int(a) + int(b)) / 2)
+			case 4:
+				cur[i] += paeth(a, b, c)
+			}
+		}
+		out = append(out, cur...)
+		prev = cur
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	} else if pb <= pc {
+		return b
+	}
+	return c
+}
+
+// Absolute value
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func asciiHex(data []byte) []byte {
+	var out []byte
+	hi, odd := byte(0), false
+	for _, c := range data {
+		if c == '>' {
+			break
+		}
+		v, ok := unhex(c)
+		if !ok {
+			continue
+		}
+		if odd {
+			out = append(out, hi<<4|v)
+		} else {
+			hi = v
+		}
+		odd = !odd
+	}
+	if odd {
+		out = append(out, hi<<4)
+	}
+	return out
+}
+
+func ascii85Decode(data []byte) ([]byte, error) {
+	if i := bytes.Index(data, []byte("~>")); i >= 0 {
+		data = data[:i]
+	}
+	data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))
+	out := make([]byte, len(data))
+	n, _, err := ascii85.Decode(out, data, true)
+	if err != nil {
+		return nil, fmt.Errorf("ASCII85Decode: %v", err)
+	}
+	return out[:n], nil
+}
+
+func runLength(data []byte) []byte {
+	var out []byte
+	for len(data) > 0 && data[0] != 128 {
+		n := int(data[0])
+		data = data[1:]
+		if n < 128 {
+			if n+1 > len(data) {
+				n = len(data) - 1
+			}
+			out = append(out, data[:n+1]...)
+			data = data[n+1:]
+		} else if len(data) > 0 {
+			out = append(out, bytes.Repeat(data[:1], 257-n)...)
+			data = data[1:]
+		}
+	}
+	return out
+}
@@ -0,0 +1,289 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// Objects are nil, bool, int, float64, string, name, keyword, []object,
+// dict, ref or *stream
+type object interface{}
+
+// Name such as /Type, without the slash
+type name string
+
+// Bare word such as obj, R or a content stream operator
+type keyword string
+
+type dict map[name]object
+
+// Indirect reference such as 12 0 R
+type ref struct {
+	num, gen int
+}
+
+// Stream with its dictionary and the undecoded bytes that follow it
+type stream struct {
+	hdr dict
+	raw []byte
+}
+
+// Reads objects from PDF bytes. Malformed input yields errors, not panics
+type lexer struct {
+	data []byte
+	pos  int
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isDelim(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+// Skips whitespace and comments
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\r' && l.data[l.pos] != '\n' {
+				l.pos++
+			}
+		} else if isSpace(c) {
+			l.pos++
+		} else {
+			return
+		}
+	}
+}
+
+// Returns the next regular word, i.e. a number or keyword
+func (l *lexer) word() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isSpace(l.data[l.pos]) && !isDelim(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+// Reads the next object. Returns a keyword for anything that is not a
+// value, such as an operator, endobj or the closing ] of an array
+func (l *lexer) object() (object, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, fmt.Errorf("Unexpected end of data at %d", l.pos)
+	}
+	switch c := l.data[l.pos]; {
+	case c == '/':
+		l.pos++
+		return l.name(), nil
+	case c == '(':
+		l.pos++
+		return l.literal()
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		return l.dict()
+	case c == '<':
+		l.pos++
+		return l.hex()
+	case c == '[':
+		l.pos++
+		return l.array()
+	case c == ']' || c == '>' || c == '{' || c == '}' || c == ')':
+		l.pos++
+		if c == '>' && l.pos < len(l.data) && l.data[l.pos] == '>' {
+			l.pos++
+			return keyword(">>"), nil
+		}
+		return keyword(string(c)), nil
+	}
+	w := l.word()
+	switch w {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	if n, err := strconv.Atoi(w); err == nil {
+		// Either an integer or the start of a reference: num gen R
+		save := l.pos
+		l.skipSpace()
+		if g, err := strconv.Atoi(l.word()); err == nil {
+			l.skipSpace()
+			if l.word() == "R" {
+				return ref{n, g}, nil
+			}
+		}
+		l.pos = save
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(w, 64); err == nil {
+		return f, nil
+	}
+	if len(w) > 0 && (w[0] == '-' || w[0] == '+' || w[0] == '.' || (w[0] >= '0' && w[0] <= '9')) {
+		// Malformed numbers such as 0.-5 or 1..2 are read as 0
+		return 0.0, nil
+	}
+	if w == "" {
+		return nil, fmt.Errorf("Unexpected %q at %d", l.data[l.pos], l.pos)
+	}
+	return keyword(w), nil
+}
+
+// Reads a name after its slash, decoding #xx escapes
+func (l *lexer) name() name {
+	w := l.word()
+	if !bytes.ContainsRune([]byte(w), '#') {
+		return name(w)
+	}
+	var b []byte
+	for i := 0; i < len(w); i++ {
+		if w[i] == '#' && i+2 < len(w) {
+			if v, err := strconv.ParseUint(w[i+1:i+3], 16, 8); err == nil {
+				b = append(b, byte(v))
+				i += 2
+				continue
+			}
+		}
+		b = append(b, w[i])
+	}
+	return name(b)
+}
+
+// Reads a (literal string) after its open parenthesis
+func (l *lexer) literal() (string, error) {
+	var b []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return string(b), nil
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				break
+			}
+			c = l.data[l.pos]
+			l.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue // Line continuation
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					v := int(c - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return "", fmt.Errorf("Unterminated string")
+}
+
+// Reads a <hex string> after its open angle bracket
+func (l *lexer) hex() (string, error) {
+	var b []byte
+	hi, odd := byte(0), false
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		if c == '>' {
+			if odd {
+				b = append(b, hi<<4)
+			}
+			return string(b), nil
+		}
+		v, ok := unhex(c)
+		if !ok {
+			continue // Whitespace and junk are ignored
+		}
+		if odd {
+			b = append(b, hi<<4|v)
+		} else {
+			hi = v
+		}
+		odd = !odd
+	}
+	return "", fmt.Errorf("Unterminated hex string")
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// Reads an [array] after its open bracket
+func (l *lexer) array() ([]object, error) {
+	var a []object
+	for {
+		o, err := l.object()
+		if err != nil {
+			return nil, err
+		}
+		if o == keyword("]") {
+			return a, nil
+		}
+		a = append(a, o)
+	}
+}
+
+// Reads a <<dictionary>> after its open brackets
+func (l *lexer) dict() (dict, error) {
+	d := make(dict)
+	for {
+		k, err := l.object()
+		if err != nil {
+			return nil, err
+		}
+		if k == keyword(">>") {
+			return d, nil
+		}
+		key, ok := k.(name)
+		if !ok {
+			return nil, fmt.Errorf("Expected a name key instead of: %v", k)
+		}
+		v, err := l.object()
+		if err != nil {
+			return nil, err
+		}
+		if v == keyword(">>") {
+			return d, nil // Missing value
+		}
+		d[key] = v
+	}
+}
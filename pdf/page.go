@@ -0,0 +1,216 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Attributes that pages inherit from their ancestors in the page tree
+var inherited = []name{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// Flattens the page tree into r.pages, copying inherited attributes down
+func (r *Reader) loadPages() error {
+	seen := make(map[int]bool)
+	var walk func(node dict, parent dict, depth int) error
+	walk = func(node dict, parent dict, depth int) error {
+		if depth > 64 {
+			return fmt.Errorf("Page tree is too deep")
+		}
+		page := make(dict, len(node))
+		for k, v := range node {
+			page[k] = v
+		}
+		for _, k := range inherited {
+			if page[k] == nil && parent != nil {
+				page[k] = parent[k]
+			}
+		}
+		kids, isTree := r.resolve(node["Kids"]).([]object)
+		if !isTree || node["Type"] == name("Page") {
+			r.pages = append(r.pages, page)
+			return nil
+		}
+		for _, kid := range kids {
+			// Skip kids seen before, which would otherwise loop forever
+			if x, ok := kid.(ref); ok {
+				if seen[x.num] {
+					continue
+				}
+				seen[x.num] = true
+			}
+			if d, ok := r.resolve(kid).(dict); ok {
+				if err := walk(d, page, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	pages, ok := r.resolve(r.root()["Pages"]).(dict)
+	if !ok {
+		return fmt.Errorf("Missing page tree")
+	}
+	return walk(pages, nil, 0)
+}
+
+// Returns the number of pages
+func (r *Reader) NumPage() int {
+	return len(r.pages)
+}
+
+// Returns page n, numbered from 1
+func (r *Reader) page(n int) (dict, error) {
+	if n < 1 || n > len(r.pages) {
+		return nil, fmt.Errorf("Page %d is not within [1, %d]", n, len(r.pages))
+	}
+	return r.pages[n-1], nil
+}
+
+// Returns the visible area of the page as x0, y0, x1, y1 in points
+func (r *Reader) pageBox(page dict) [4]float64 {
+	box := r.numbers(page["CropBox"])
+	if len(box) != 4 {
+		box = r.numbers(page["MediaBox"])
+	}
+	if len(box) != 4 {
+		box = []float64{0, 0, 612, 792} // US Letter
+	}
+	if box[0] > box[2] {
+		box[0], box[2] = box[2], box[0]
+	}
+	if box[1] > box[3] {
+		box[1], box[3] = box[3], box[1]
+	}
+	return [4]float64{box[0], box[1], box[2], box[3]}
+}
+
+// Returns the page's decoded content streams joined together
+func (r *Reader) contents(page dict) ([]byte, error) {
+	var streams []object
+	switch c := r.resolve(page["Contents"]).(type) {
+	case *stream:
+		streams = []object{c}
+	case []object:
+		streams = c
+	}
+	var buf bytes.Buffer
+	for _, o := range streams {
+		s, ok := r.resolve(o).(*stream)
+		if !ok {
+			continue
+		}
+		data, err := r.decode(s)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// Calls op for each operator in the content stream with its operands. Skips
+// inline images. Stops at the first error from op
+func operators(content []byte, op func(op string, args []object) error) error {
+	l := &lexer{data: content}
+	var args []object
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.data) {
+			return nil
+		}
+		o, err := l.object()
+		if err != nil {
+			// Skip the offending byte rather than give up on the page
+			l.pos++
+			args = args[:0]
+			continue
+		}
+		k, ok := o.(keyword)
+		if !ok {
+			args = append(args, o)
+			continue
+		}
+		if k == "BI" {
+			skipInlineImage(l)
+			args = args[:0]
+			continue
+		}
+		if err := op(string(k), args); err != nil {
+			return err
+		}
+		args = args[:0]
+	}
+}
+
+// Skips past the EI that ends an inline image
+func skipInlineImage(l *lexer) {
+	i := bytes.Index(l.data[l.pos:], []byte("ID"))
+	if i < 0 {
+		l.pos = len(l.data)
+		return
+	}
+	l.pos += i + 2
+	for {
+		j := bytes.Index(l.data[l.pos:], []byte("EI"))
+		if j < 0 {
+			l.pos = len(l.data)
+			return
+		}
+		l.pos += j + 2
+		if isSpace(l.data[l.pos-3]) && (l.pos == len(l.data) || isSpace(l.data[l.pos])) {
+			return
+		}
+	}
+}
+
+// Affine transformation [a b c d e f] mapping (x, y) to
+// (a*x + c*y + e, b*x + d*y + f)
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+// Returns m then n, i.e. m×n
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func (m matrix) apply(x, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+// Reads six numeric operands as a matrix
+func (r *Reader) matrixOf(args []object) (matrix, bool) {
+	var m matrix
+	if len(args) < 6 {
+		return m, false
+	}
+	for i := range m {
+		var ok bool
+		if m[i], ok = r.number(args[len(args)-6+i]); !ok {
+			return m, false
+		}
+	}
+	return m, true
+}
+
+// Returns the /Matrix of a form XObject
+func (r *Reader) formMatrix(s *stream) matrix {
+	a, _ := r.resolve(s.hdr["Matrix"]).([]object)
+	if m, ok := r.matrixOf(a); ok {
+		return m
+	}
+	return identity
+}
+
+// Returns the named resource of the given category, e.g. XObject or Font
+func (r *Reader) resource(resources dict, category, key name) object {
+	return r.resolve(r.dictOf(resources, category)[key])
+}
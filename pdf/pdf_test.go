@@ -0,0 +1,297 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Encodes a w x h image, dark on its left half and light on its right
+func halves(t *testing.T, w, h int, format string) []byte {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetGray(x, y, color.Gray{230})
+			if x < w/2 {
+				img.SetGray(x, y, color.Gray{20})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	var err error
+	if format == "jpg" {
+		err = jpeg.Encode(&buf, img, nil)
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// Returns a two page PDF: a JPEG under text, then a PNG on the left half
+// of the page
+func scanned(t *testing.T) []byte {
+	doc := gofpdf.New("P", "pt", "", "")
+	doc.SetFont("Helvetica", "", 12)
+	for i, format := range []string{"jpg", "png"} {
+		doc.AddPageFormat("P", gofpdf.SizeType{Wd: 200, Ht: 100})
+		name := fmt.Sprintf("page%d", i)
+		opt := gofpdf.ImageOptions{ImageType: format}
+		doc.RegisterImageOptionsReader(name, opt, bytes.NewReader(halves(t, 80, 40, format)))
+		doc.Text(10, 20, fmt.Sprintf("Hello world %d", i+1))
+		doc.Text(10, 60, "second line")
+		if i == 0 {
+			doc.ImageOptions(name, 0, 0, 200, 100, false, opt, 0, "")
+		} else {
+			doc.ImageOptions(name, 0, 0, 100, 100, false, opt, 0, "")
+		}
+	}
+	var buf bytes.Buffer
+	if err := doc.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func gray(img image.Image, x, y int) uint8 {
+	return color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+}
+
+func TestRenderPage(t *testing.T) {
+	r, err := NewReader(scanned(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.NumPage() != 2 {
+		t.Fatalf("Expected 2 pages. Received: %d", r.NumPage())
+	}
+	img, err := r.RenderPage(1, 144)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 400 || b.Dy() != 200 {
+		t.Fatalf("Expected 400x200 at 144 dpi. Received: %v", b)
+	}
+	if g := gray(img, 100, 100); g > 60 {
+		t.Fatalf("Expected the dark half of the jpeg. Received: %d", g)
+	}
+	if g := gray(img, 300, 100); g < 190 {
+		t.Fatalf("Expected the light half of the jpeg. Received: %d", g)
+	}
+	img, err = r.RenderPage(2, 72)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct{ x, y, min, max int }{
+		{25, 50, 20, 20},    // Dark half of the png
+		{75, 50, 230, 230},  // Light half of the png
+		{150, 50, 255, 255}, // Blank page
+	} {
+		if g := int(gray(img, c.x, c.y)); g < c.min || g > c.max {
+			t.Fatalf("Expected %d at (%d, %d). Received: %d", c.min, c.x, c.y, g)
+		}
+	}
+	if _, err := r.RenderPage(3, 72); err == nil {
+		t.Fatal("Expected an error for a page past the end")
+	}
+}
+
+func TestPageText(t *testing.T) {
+	r, err := NewReader(scanned(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 2; i++ {
+		text, err := r.PageText(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := fmt.Sprintf("Hello world %d\nsecond line\n", i)
+		if text != want {
+			t.Fatalf("Expected %q. Received: %q", want, text)
+		}
+	}
+}
+
+// Writes the objects numbered from 1 with a classic xref table. The
+// trailer's /Root is object 1
+func build(objs ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objs))
+	for i, o := range objs {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, xref)
+	return buf.Bytes()
+}
+
+func streamObj(hdr string, data []byte) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", hdr, len(data), data)
+}
+
+func deflate(data []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+// A page showing text in a two byte font with a ToUnicode CMap
+var textPage = []string{
+	"<< /Type /Catalog /Pages 2 0 R >>",
+	"<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 300 200] >>",
+	"<< /Type /Page /Parent 2 0 R /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+	streamObj("", []byte("BT /F1 10 Tf 1 0 0 1 10 150 Tm [<00010002> -3000 <0003>] TJ 0 -20 Td <0004> Tj ET")),
+	"<< /Type /Font /Subtype /Type0 /BaseFont /X /Encoding /Identity-H /DescendantFonts [6 0 R] /ToUnicode 7 0 R >>",
+	"<< /Type /Font /Subtype /CIDFontType2 /DW 500 /W [1 [600 400]] >>",
+	streamObj("", []byte("begincmap\n1 begincodespacerange <0000> <FFFF> endcodespacerange\n"+
+		"1 beginbfchar <0003> <00E9> endbfchar\n"+
+		"2 beginbfrange <0001> <0002> <0048> <0004> <0004> [<0066006C>] endbfrange\nendcmap")),
+}
+
+func TestToUnicode(t *testing.T) {
+	r, err := NewReader(build(textPage...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := r.PageText(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "HI é\nfl\n" {
+		t.Fatalf("Expected the CMap's text. Received: %q", text)
+	}
+	if _, err := r.RenderPage(1, 72); err == nil {
+		t.Fatal("Expected an error for a page of text alone")
+	}
+}
+
+// Moves objects 1 and 2 into an object stream and indexes all objects with a
+// compressed xref stream, using the PNG Up predictor
+func compressed(objs []string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.5\n")
+	offsets := make([]int, len(objs)+3)
+	for i := 2; i < len(objs); i++ {
+		offsets[i+1] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, objs[i])
+	}
+	stm := len(objs) + 1
+	index := fmt.Sprintf("1 0 2 %d ", len(objs[0])+1)
+	body := index + objs[0] + "\n" + objs[1]
+	offsets[stm] = buf.Len()
+	fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", stm, streamObj(
+		fmt.Sprintf("/Type /ObjStm /N 2 /First %d /Filter /FlateDecode", len(index)), deflate([]byte(body))))
+	// Rows of type, offset and index, each prefixed by the Up predictor
+	xref := stm + 1
+	offsets[xref] = buf.Len()
+	var rows, prev []byte
+	prev = make([]byte, 4)
+	for num := 0; num <= xref; num++ {
+		row := []byte{1, 0, byte(offsets[num] >> 8), byte(offsets[num])}
+		switch {
+		case num == 0:
+			row = []byte{0, 0, 0, 0}
+		case num <= 2:
+			row = []byte{2, 0, byte(stm), byte(num - 1)}
+		}
+		rows = append(rows, 2)
+		for i := range row {
+			rows = append(rows, row[i]-prev[i])
+		}
+		prev = row
+	}
+	fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", xref, streamObj(fmt.Sprintf(
+		"/Type /XRef /Size %d /W [1 2 1] /Root 1 0 R /Filter /FlateDecode /DecodeParms << /Predictor 12 /Columns 4 >>",
+		xref+1), deflate(rows)))
+	fmt.Fprintf(&buf, "startxref\n%d\n%%%%EOF\n", offsets[xref])
+	return buf.Bytes()
+}
+
+func TestXrefStream(t *testing.T) {
+	data := compressed(textPage)
+	r, err := NewReader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, err := r.PageText(1); err != nil || text != "HI é\nfl\n" {
+		t.Fatalf("Expected text through the xref stream. Received: %q, %v", text, err)
+	}
+	// Without the xref stream, objects are found by scanning
+	i := bytes.LastIndex(data, []byte("startxref"))
+	broken := append(append([]byte{}, data[:i]...), "startxref\n9\n%%EOF\n"...)
+	r, err = NewReader(broken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, err := r.PageText(1); err != nil || text != "HI é\nfl\n" {
+		t.Fatalf("Expected text after scanning. Received: %q, %v", text, err)
+	}
+}
+
+func TestBrokenXref(t *testing.T) {
+	data := scanned(t)
+	i := bytes.LastIndex(data, []byte("startxref"))
+	r, err := NewReader(append(append([]byte{}, data[:i]...), "startxref\n123\n%%EOF\n"...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.NumPage() != 2 {
+		t.Fatalf("Expected 2 pages after scanning. Received: %d", r.NumPage())
+	}
+}
+
+func TestImageMask(t *testing.T) {
+	// A 16x2 stencil painting its first 8 columns, scaled over the page
+	mask := []byte{0x00, 0xff, 0x00, 0xff}
+	r, err := NewReader(build(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 160 20] /Rotate 90 /Contents 4 0 R /Resources << /XObject << /Im 5 0 R >> >> >>",
+		streamObj("", []byte("q 160 0 0 20 0 0 cm /Im Do Q")),
+		streamObj("/Type /XObject /Subtype /Image /Width 16 /Height 2 /ImageMask true /Filter /ASCIIHexDecode", []byte(fmt.Sprintf("%x>", mask))),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := r.RenderPage(1, 72)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rotated clockwise, the left of the page is at the top
+	if b := img.Bounds(); b.Dx() != 20 || b.Dy() != 160 {
+		t.Fatalf("Expected 20x160 after rotating. Received: %v", b)
+	}
+	if g := gray(img, 10, 40); g != 0 {
+		t.Fatalf("Expected the mask painted black. Received: %d", g)
+	}
+	if g := gray(img, 10, 120); g != 255 {
+		t.Fatalf("Expected white outside the mask. Received: %d", g)
+	}
+}
+
+func TestEncrypted(t *testing.T) {
+	data := build("<< /Type /Catalog /Pages 2 0 R >>", "<< /Type /Pages /Kids [] /Count 0 >>")
+	data = bytes.Replace(data, []byte("/Root 1 0 R"), []byte("/Root 1 0 R /Encrypt << /Filter /Standard >>"), 1)
+	if _, err := NewReader(data); err == nil || !strings.Contains(err.Error(), "Encrypted") {
+		t.Fatalf("Expected an encryption error. Received: %v", err)
+	}
+}
@@ -0,0 +1,470 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+)
+
+// Where an object is stored: at a byte offset, or inside an object stream
+type xrefEntry struct {
+	offset     int
+	stm, index int
+	compressed bool
+}
+
+// Reader of an unencrypted PDF held in memory
+type Reader struct {
+	data    []byte
+	xref    map[int]xrefEntry
+	trailer dict
+	cache   map[int]object
+	loading map[int]bool
+	pages   []dict
+}
+
+// Opens the PDF at path
+func Open(path string) (*Reader, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(data)
+}
+
+// Reads the PDF in data. Rebuilds a broken cross-reference table by
+// scanning for objects
+func NewReader(data []byte) (*Reader, error) {
+	r := &Reader{data: data, cache: make(map[int]object), loading: make(map[int]bool)}
+	if err := r.readXref(); err != nil || r.root() == nil {
+		if err := r.scanXref(); err != nil {
+			return nil, err
+		}
+	}
+	if r.trailer["Encrypt"] != nil {
+		return nil, fmt.Errorf("Encrypted PDFs are not supported")
+	}
+	if r.root() == nil {
+		return nil, fmt.Errorf("Missing document catalog")
+	}
+	if err := r.loadPages(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Returns the document catalog
+func (r *Reader) root() dict {
+	d, _ := r.resolve(r.trailer["Root"]).(dict)
+	return d
+}
+
+// Reads the cross-reference sections from startxref back through /Prev
+func (r *Reader) readXref() error {
+	i := bytes.LastIndex(r.data, []byte("startxref"))
+	if i < 0 {
+		return fmt.Errorf("Missing startxref")
+	}
+	l := &lexer{data: r.data, pos: i + len("startxref")}
+	o, err := l.object()
+	if err != nil {
+		return err
+	}
+	offset, ok := o.(int)
+	if !ok {
+		return fmt.Errorf("Expected startxref offset instead of: %v", o)
+	}
+	r.xref = make(map[int]xrefEntry)
+	seen := make(map[int]bool)
+	for offset > 0 && !seen[offset] {
+		seen[offset] = true
+		trailer, err := r.readXrefSection(offset)
+		if err != nil {
+			return err
+		}
+		if r.trailer == nil {
+			r.trailer = trailer
+		}
+		// Hybrid files list their compressed objects in a stream too
+		if stm, ok := trailer["XRefStm"].(int); ok && !seen[stm] {
+			seen[stm] = true
+			if _, err := r.readXrefSection(stm); err != nil {
+				return err
+			}
+		}
+		offset, _ = trailer["Prev"].(int)
+	}
+	return nil
+}
+
+// Reads a table or stream at offset. Earlier entries take precedence since
+// sections are read from newest to oldest
+func (r *Reader) readXrefSection(offset int) (dict, error) {
+	if offset >= len(r.data) {
+		return nil, fmt.Errorf("Xref offset %d is past the end", offset)
+	}
+	l := &lexer{data: r.data, pos: offset}
+	l.skipSpace()
+	if bytes.HasPrefix(r.data[l.pos:], []byte("xref")) {
+		l.pos += len("xref")
+		return r.readXrefTable(l)
+	}
+	o, err := r.readIndirect(l)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := o.(*stream)
+	if !ok || s.hdr["Type"] != name("XRef") {
+		return nil, fmt.Errorf("Expected xref at %d", offset)
+	}
+	return s.hdr, r.readXrefStream(s)
+}
+
+// Reads subsections of "first count" lines of "offset gen n|f" entries
+func (r *Reader) readXrefTable(l *lexer) (dict, error) {
+	for {
+		o, err := l.object()
+		if err != nil {
+			return nil, err
+		}
+		if o == keyword("trailer") {
+			o, err = l.object()
+			if err != nil {
+				return nil, err
+			}
+			trailer, ok := o.(dict)
+			if !ok {
+				return nil, fmt.Errorf("Expected trailer dictionary instead of: %v", o)
+			}
+			return trailer, nil
+		}
+		first, ok := o.(int)
+		if !ok {
+			return nil, fmt.Errorf("Expected xref subsection instead of: %v", o)
+		}
+		o, err = l.object()
+		count, ok := o.(int)
+		if err != nil || !ok {
+			return nil, fmt.Errorf("Expected xref count instead of: %v", o)
+		}
+		for i := 0; i < count; i++ {
+			off, _ := l.object()
+			_, _ = l.object()
+			kind, err := l.object()
+			if err != nil {
+				return nil, err
+			}
+			num := first + i
+			if _, ok := r.xref[num]; ok {
+				continue
+			}
+			if n, ok := off.(int); ok && kind == keyword("n") {
+				r.xref[num] = xrefEntry{offset: n}
+			} else {
+				r.xref[num] = xrefEntry{} // Free
+			}
+		}
+	}
+}
+
+// Reads the binary entries of a cross-reference stream
+func (r *Reader) readXrefStream(s *stream) error {
+	data, err := r.decode(s)
+	if err != nil {
+		return err
+	}
+	var w [3]int
+	ws, _ := r.resolve(s.hdr["W"]).([]object)
+	if len(ws) != 3 {
+		return fmt.Errorf("Expected xref /W of 3 widths instead of: %v", ws)
+	}
+	for i := range w {
+		w[i], _ = ws[i].(int)
+		if w[i] < 0 || w[i] > 8 {
+			return fmt.Errorf("Invalid xref /W: %v", ws)
+		}
+	}
+	index, _ := r.resolve(s.hdr["Index"]).([]object)
+	if index == nil {
+		size, _ := s.hdr["Size"].(int)
+		index = []object{0, size}
+	}
+	field := func(b []byte) int {
+		v := 0
+		for _, c := range b {
+			v = v<<8 | int(c)
+		}
+		return v
+	}
+	n := w[0] + w[1] + w[2]
+	for i := 0; i+1 < len(index); i += 2 {
+		first, _ := index[i].(int)
+		count, _ := index[i+1].(int)
+		for j := 0; j < count && len(data) >= n; j++ {
+			kind := 1 // The default when the type field is omitted
+			if w[0] > 0 {
+				kind = field(data[:w[0]])
+			}
+			a, b := field(data[w[0]:w[0]+w[1]]), field(data[w[0]+w[1]:n])
+			data = data[n:]
+			num := first + j
+			if _, ok := r.xref[num]; ok {
+				continue
+			}
+			switch kind {
+			case 1:
+				r.xref[num] = xrefEntry{offset: a}
+			case 2:
+				r.xref[num] = xrefEntry{stm: a, index: b, compressed: true}
+			default:
+				r.xref[num] = xrefEntry{}
+			}
+		}
+	}
+	return nil
+}
+
+var objPattern = regexp.MustCompile(`(\d+)[ \t\r\n\f\x00]+(\d+)[ \t\r\n\f\x00]+obj\b`)
+
+// Rebuilds the cross-reference table by finding every "num gen obj". Later
+// definitions win, as they would in an incremental update
+func (r *Reader) scanXref() error {
+	r.xref = make(map[int]xrefEntry)
+	r.trailer = nil
+	r.cache = make(map[int]object)
+	for _, m := range objPattern.FindAllSubmatchIndex(r.data, -1) {
+		// Only count matches at the start of a line
+		if m[0] > 0 && !isSpace(r.data[m[0]-1]) {
+			continue
+		}
+		num, err := strconv.Atoi(string(r.data[m[2]:m[3]]))
+		if err != nil {
+			continue
+		}
+		r.xref[num] = xrefEntry{offset: m[0]}
+	}
+	// Index the objects within object streams unless defined directly
+	var nums []int
+	for num := range r.xref {
+		nums = append(nums, num)
+	}
+	for _, num := range nums {
+		s, ok := r.object(num).(*stream)
+		if !ok {
+			continue
+		}
+		switch s.hdr["Type"] {
+		case name("ObjStm"):
+			offsets, _, err := r.objStmOffsets(s)
+			if err != nil {
+				continue
+			}
+			for i, o := range offsets {
+				if _, ok := r.xref[o[0]]; !ok {
+					r.xref[o[0]] = xrefEntry{stm: num, index: i, compressed: true}
+				}
+			}
+		case name("XRef"):
+			if r.trailer == nil || r.trailer["Root"] == nil {
+				r.trailer = s.hdr
+			}
+		}
+	}
+	for i := 0; ; {
+		j := bytes.Index(r.data[i:], []byte("trailer"))
+		if j < 0 {
+			break
+		}
+		i += j + len("trailer")
+		l := &lexer{data: r.data, pos: i}
+		if o, err := l.object(); err == nil {
+			if d, ok := o.(dict); ok && d["Root"] != nil {
+				r.trailer = d
+			}
+		}
+	}
+	if r.trailer == nil {
+		// Find the catalog directly
+		for num := range r.xref {
+			if d, ok := r.object(num).(dict); ok && d["Type"] == name("Catalog") {
+				r.trailer = dict{"Root": ref{num, 0}}
+				break
+			}
+		}
+	}
+	if r.trailer == nil {
+		return fmt.Errorf("Not a PDF: no trailer or catalog")
+	}
+	return nil
+}
+
+// Reads "num gen obj" and the object that follows
+func (r *Reader) readIndirect(l *lexer) (object, error) {
+	for _, want := range []string{"num", "gen", "obj"} {
+		o, err := l.object()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := o.(int); want != "obj" && !ok {
+			return nil, fmt.Errorf("Expected object %s instead of: %v", want, o)
+		}
+		if want == "obj" && o != keyword("obj") {
+			return nil, fmt.Errorf("Expected obj instead of: %v", o)
+		}
+	}
+	o, err := l.object()
+	if err != nil {
+		return nil, err
+	}
+	d, ok := o.(dict)
+	if !ok {
+		return o, nil
+	}
+	save := l.pos
+	l.skipSpace()
+	if l.word() != "stream" {
+		l.pos = save
+		return d, nil
+	}
+	// Data starts after the end of line following the keyword
+	if l.pos < len(l.data) && l.data[l.pos] == '\r' {
+		l.pos++
+	}
+	if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+		l.pos++
+	}
+	start := l.pos
+	end := -1
+	if n, ok := r.resolve(d["Length"]).(int); ok && n >= 0 && start+n <= len(l.data) {
+		rest := bytes.TrimLeft(l.data[start+n:], " \t\r\n\f\x00")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			end = start + n
+		}
+	}
+	if end < 0 {
+		// Wrong or missing /Length
+		i := bytes.Index(l.data[start:], []byte("endstream"))
+		if i < 0 {
+			return nil, fmt.Errorf("Missing endstream")
+		}
+		end = start + i
+		for end > start && (l.data[end-1] == '\n' || l.data[end-1] == '\r') {
+			end--
+		}
+	}
+	return &stream{hdr: d, raw: l.data[start:end]}, nil
+}
+
+// Returns the object with the given number, or nil if missing or broken
+func (r *Reader) object(num int) object {
+	if o, ok := r.cache[num]; ok {
+		return o
+	}
+	e, ok := r.xref[num]
+	if !ok || r.loading[num] {
+		return nil
+	}
+	r.loading[num] = true
+	defer delete(r.loading, num)
+	var o object
+	if e.compressed {
+		o = r.compressed(e.stm, e.index)
+	} else if e.offset > 0 && e.offset < len(r.data) {
+		o, _ = r.readIndirect(&lexer{data: r.data, pos: e.offset})
+	}
+	r.cache[num] = o
+	return o
+}
+
+// Returns the object numbers and offsets in an object stream, and where the
+// objects begin
+func (r *Reader) objStmOffsets(s *stream) ([][2]int, []byte, error) {
+	data, err := r.decode(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	n, _ := s.hdr["N"].(int)
+	first, _ := s.hdr["First"].(int)
+	if first > len(data) {
+		return nil, nil, fmt.Errorf("Invalid object stream /First %d", first)
+	}
+	l := &lexer{data: data[:first]}
+	offsets := make([][2]int, 0, n)
+	for i := 0; i < n; i++ {
+		a, err1 := l.object()
+		b, err2 := l.object()
+		num, ok1 := a.(int)
+		off, ok2 := b.(int)
+		if err1 != nil || err2 != nil || !ok1 || !ok2 {
+			break
+		}
+		offsets = append(offsets, [2]int{num, off})
+	}
+	return offsets, data[first:], nil
+}
+
+// Returns the object at index within object stream stm
+func (r *Reader) compressed(stm, index int) object {
+	s, ok := r.object(stm).(*stream)
+	if !ok {
+		return nil
+	}
+	offsets, data, err := r.objStmOffsets(s)
+	if err != nil || index >= len(offsets) || offsets[index][1] > len(data) {
+		return nil
+	}
+	o, err := (&lexer{data: data, pos: offsets[index][1]}).object()
+	if err != nil {
+		return nil
+	}
+	return o
+}
+
+// Follows references until reaching a direct object
+func (r *Reader) resolve(o object) object {
+	for i := 0; i < 32; i++ {
+		x, ok := o.(ref)
+		if !ok {
+			return o
+		}
+		o = r.object(x.num)
+	}
+	return nil
+}
+
+// Resolves the value of key as a dictionary, including a stream's
+func (r *Reader) dictOf(d dict, key name) dict {
+	switch v := r.resolve(d[key]).(type) {
+	case dict:
+		return v
+	case *stream:
+		return v.hdr
+	}
+	return nil
+}
+
+// Resolves a number as a float
+func (r *Reader) number(o object) (float64, bool) {
+	switch v := r.resolve(o).(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// Resolves an array of numbers, or returns nil
+func (r *Reader) numbers(o object) []float64 {
+	a, _ := r.resolve(o).([]object)
+	f := make([]float64, len(a))
+	for i := range a {
+		var ok bool
+		if f[i], ok = r.number(a[i]); !ok {
+			return nil
+		}
+	}
+	return f
+}
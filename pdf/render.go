@@ -0,0 +1,461 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"math"
+)
+
+// Draws the images of page n at dpi on white. Pages are numbered from 1.
+// Returns an error for pages drawn with text or vector graphics alone,
+// since those are not rendered
+func (r *Reader) RenderPage(n int, dpi float64) (image.Image, error) {
+	page, err := r.page(n)
+	if err != nil {
+		return nil, err
+	}
+	box := r.pageBox(page)
+	scale := dpi / 72
+	w := int(math.Round((box[2] - box[0]) * scale))
+	h := int(math.Round((box[3] - box[1]) * scale))
+	if w < 1 || h < 1 || w*h > 1<<28 {
+		return nil, fmt.Errorf("Page %d of %.0fx%.0f points is too large or small at %.0f dpi", n, box[2]-box[0], box[3]-box[1], dpi)
+	}
+	content, err := r.contents(page)
+	if err != nil {
+		return nil, fmt.Errorf("Page %d: %v", n, err)
+	}
+	resources, _ := r.resolve(page["Resources"]).(dict)
+	p := &painter{r: r, canvas: image.NewRGBA(image.Rect(0, 0, w, h)), gray: true}
+	draw.Draw(p.canvas, p.canvas.Bounds(), image.White, image.Point{}, draw.Src)
+	// Flip y so the top-left of the page is (0, 0)
+	ctm := matrix{scale, 0, 0, -scale, -box[0] * scale, box[3] * scale}
+	if err := p.paint(content, resources, ctm, 0); err != nil {
+		return nil, fmt.Errorf("Page %d: %v", n, err)
+	}
+	if p.images == 0 && p.marks {
+		return nil, fmt.Errorf("Page %d has no images; only scanned pages can be rendered", n)
+	}
+	rotate, _ := r.resolve(page["Rotate"]).(int)
+	var img image.Image = rotated(p.canvas, rotate)
+	if p.gray {
+		img = toGray(img)
+	}
+	return img, nil
+}
+
+// Paints the images of a content stream onto the canvas
+type painter struct {
+	r      *Reader
+	canvas *image.RGBA
+	images int  // Number of images drawn
+	marks  bool // Whether text or paths were skipped
+	gray   bool // Whether every image drawn was grayscale
+}
+
+func (p *painter) paint(content []byte, resources dict, ctm matrix, depth int) error {
+	if depth > 16 {
+		return fmt.Errorf("Forms are nested too deeply")
+	}
+	var stack []matrix
+	return operators(content, func(op string, args []object) error {
+		switch op {
+		case "q":
+			stack = append(stack, ctm)
+		case "Q":
+			if len(stack) > 0 {
+				ctm = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case "cm":
+			if m, ok := p.r.matrixOf(args); ok {
+				ctm = m.mul(ctm)
+			}
+		case "Tj", "TJ", "'", "\"", "f", "F", "f*", "S", "s", "B", "B*", "b", "b*", "sh":
+			p.marks = true
+		case "Do":
+			if len(args) < 1 {
+				return nil
+			}
+			key, _ := args[0].(name)
+			s, ok := p.r.resource(resources, "XObject", key).(*stream)
+			if !ok {
+				return nil
+			}
+			switch s.hdr["Subtype"] {
+			case name("Image"):
+				return p.image(s, ctm)
+			case name("Form"):
+				m := p.r.formMatrix(s)
+				data, err := p.r.decode(s)
+				if err != nil {
+					return err
+				}
+				res, ok := p.r.resolve(s.hdr["Resources"]).(dict)
+				if !ok {
+					res = resources
+				}
+				return p.paint(data, res, m.mul(ctm), depth+1)
+			}
+		}
+		return nil
+	})
+}
+
+// Draws an image XObject into the unit square mapped by ctm
+func (p *painter) image(s *stream, ctm matrix) error {
+	img, mask, err := p.r.decodeImageXObject(s)
+	if err != nil {
+		return err
+	}
+	p.images++
+	if _, ok := img.(*image.Gray); !ok && !mask {
+		p.gray = false
+	}
+	// Map each canvas pixel back into the image
+	det := ctm[0]*ctm[3] - ctm[1]*ctm[2]
+	if det == 0 {
+		return nil
+	}
+	inv := matrix{ctm[3] / det, -ctm[1] / det, -ctm[2] / det, ctm[0] / det, 0, 0}
+	inv[4] = -(ctm[4]*inv[0] + ctm[5]*inv[2])
+	inv[5] = -(ctm[4]*inv[1] + ctm[5]*inv[3])
+	x0, y0, x1, y1 := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, c := range [][2]float64{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+		x, y := ctm.apply(c[0], c[1])
+		x0, y0 = math.Min(x0, x), math.Min(y0, y)
+		x1, y1 = math.Max(x1, x), math.Max(y1, y)
+	}
+	area := image.Rect(int(math.Floor(x0)), int(math.Floor(y0)), int(math.Ceil(x1)), int(math.Ceil(y1))).Intersect(p.canvas.Bounds())
+	b := img.Bounds()
+	iw, ih := float64(b.Dx()), float64(b.Dy())
+	at := sampler(img)
+	// Average 2x2 samples per pixel so thin strokes survive downscaling
+	offsets := [][2]float64{{0.25, 0.25}, {0.75, 0.25}, {0.25, 0.75}, {0.75, 0.75}}
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			var sum [4]int
+			hits := 0
+			for _, o := range offsets {
+				u, v := inv.apply(float64(x)+o[0], float64(y)+o[1])
+				if u < 0 || u >= 1 || v < 0 || v >= 1 {
+					continue
+				}
+				// The first row of samples is at the top of the unit square
+				c, ok := at(b.Min.X+int(u*iw), b.Min.Y+int((1-v)*ih))
+				if !ok {
+					continue // Unmasked, so the background shows through
+				}
+				sum[0] += int(c.R)
+				sum[1] += int(c.G)
+				sum[2] += int(c.B)
+				hits++
+			}
+			if hits == 0 {
+				continue
+			}
+			i := p.canvas.PixOffset(x, y)
+			px := p.canvas.Pix[i : i+4 : i+4]
+			for k := 0; k < 3; k++ {
+				// Blend partial coverage with what is already there
+				px[k] = uint8((sum[k] + int(px[k])*(4-hits)) / 4)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns a fast pixel accessor for the image. Stencil masks are returned
+// as *image.Alpha, with unpainted samples reported as not ok
+func sampler(img image.Image) func(x, y int) (color.RGBA, bool) {
+	switch m := img.(type) {
+	case *image.Gray:
+		return func(x, y int) (color.RGBA, bool) {
+			v := m.Pix[m.PixOffset(x, y)]
+			return color.RGBA{v, v, v, 255}, true
+		}
+	case *image.Alpha:
+		return func(x, y int) (color.RGBA, bool) {
+			return color.RGBA{0, 0, 0, 255}, m.Pix[m.PixOffset(x, y)] != 0
+		}
+	case *image.RGBA:
+		return func(x, y int) (color.RGBA, bool) {
+			i := m.PixOffset(x, y)
+			return color.RGBA{m.Pix[i], m.Pix[i+1], m.Pix[i+2], 255}, true
+		}
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return sampler(rgba)
+}
+
+// Rotates the image clockwise by a multiple of 90 degrees
+func rotated(img *image.RGBA, degrees int) *image.RGBA {
+	degrees = ((degrees % 360) + 360) % 360
+	if degrees == 0 || degrees%90 != 0 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	out := image.NewRGBA(image.Rect(0, 0, h, w))
+	if degrees == 180 {
+		out = image.NewRGBA(b)
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch degrees {
+			case 90:
+				dx, dy = h-1-y, x
+			case 180:
+				dx, dy = w-1-x, h-1-y
+			case 270:
+				dx, dy = y, w-1-x
+			}
+			copy(out.Pix[out.PixOffset(dx, dy):], img.Pix[img.PixOffset(x, y):img.PixOffset(x, y)+4])
+		}
+	}
+	return out
+}
+
+// Converts the image to grayscale
+func toGray(img image.Image) *image.Gray {
+	gray := image.NewGray(img.Bounds())
+	draw.Draw(gray, gray.Bounds(), img, img.Bounds().Min, draw.Src)
+	return gray
+}
+
+// Decodes an image XObject. Stencil masks are returned as *image.Alpha
+// with mask set, painted in black
+func (r *Reader) decodeImageXObject(s *stream) (image.Image, bool, error) {
+	data, filter, err := r.decodeImage(s)
+	if err != nil {
+		return nil, false, err
+	}
+	switch filter {
+	case "":
+	case "DCTDecode", "DCT":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, false, fmt.Errorf("DCTDecode: %v", err)
+		}
+		if cmyk, ok := img.(*image.CMYK); ok && r.invertedCMYK(s) {
+			for i := range cmyk.Pix {
+				cmyk.Pix[i] = 255 - cmyk.Pix[i]
+			}
+		}
+		return img, false, nil
+	default:
+		return nil, false, fmt.Errorf("Unsupported image filter %s", filter)
+	}
+	w, _ := r.resolve(s.hdr["Width"]).(int)
+	h, _ := r.resolve(s.hdr["Height"]).(int)
+	if w <= 0 || h <= 0 || w*h > 1<<28 {
+		return nil, false, fmt.Errorf("Invalid image size %dx%d", w, h)
+	}
+	decode := r.numbers(s.hdr["Decode"])
+	if isMask, _ := r.resolve(s.hdr["ImageMask"]).(bool); isMask {
+		// 1-bit samples, painting where 0 unless /Decode is [1 0]
+		paint := byte(0)
+		if len(decode) == 2 && decode[0] == 1 {
+			paint = 1
+		}
+		m := image.NewAlpha(image.Rect(0, 0, w, h))
+		samples := newBits(data, w, 1, 1)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				if byte(samples.at(x, y, 0)) == paint {
+					m.Pix[y*m.Stride+x] = 255
+				}
+			}
+		}
+		return m, true, nil
+	}
+	bpc, _ := r.resolve(s.hdr["BitsPerComponent"]).(int)
+	if bpc != 1 && bpc != 2 && bpc != 4 && bpc != 8 && bpc != 16 {
+		return nil, false, fmt.Errorf("Unsupported %d bits per component", bpc)
+	}
+	cs, err := r.colorSpace(s.hdr["ColorSpace"])
+	if err != nil {
+		return nil, false, err
+	}
+	samples := newBits(data, w, cs.n, bpc)
+	maxValue := float64(int(1)<<uint(bpc) - 1)
+	// Maps a sample to [0, 1], or to an index for indexed images
+	ranges := make([][2]float64, cs.n)
+	for i := range ranges {
+		ranges[i] = [2]float64{0, 1}
+		if cs.lookup != nil {
+			ranges[i] = [2]float64{0, maxValue}
+		}
+		if len(decode) >= 2*cs.n {
+			ranges[i] = [2]float64{decode[2*i], decode[2*i+1]}
+		}
+	}
+	comp := make([]float64, cs.n)
+	rect := image.Rect(0, 0, w, h)
+	if cs.lookup == nil && cs.base == 1 {
+		// Write gray pixels directly, as scans are mostly gray or bilevel
+		out := image.NewGray(rect)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				v := ranges[0][0] + float64(samples.at(x, y, 0))/maxValue*(ranges[0][1]-ranges[0][0])
+				if cs.invert {
+					v = 1 - v
+				}
+				out.Pix[y*out.Stride+x] = clamp(v)
+			}
+		}
+		return out, false, nil
+	}
+	out := image.NewRGBA(rect)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			for i := range comp {
+				v := float64(samples.at(x, y, i)) / maxValue
+				comp[i] = ranges[i][0] + v*(ranges[i][1]-ranges[i][0])
+			}
+			out.Set(x, y, cs.color(comp))
+		}
+	}
+	return out, false, nil
+}
+
+// Whether a CMYK JPEG is stored inverted, as Adobe's are. Go's decoder
+// inverts Adobe JPEGs already, so only a /Decode of [1 0 ...] remains
+func (r *Reader) invertedCMYK(s *stream) bool {
+	decode := r.numbers(s.hdr["Decode"])
+	return len(decode) >= 2 && decode[0] == 1 && decode[1] == 0
+}
+
+// Packed samples of n components per pixel, with rows padded to bytes
+type bits struct {
+	data          []byte
+	n, bpc, width int
+	stride        int
+}
+
+func newBits(data []byte, width, n, bpc int) *bits {
+	return &bits{data: data, n: n, bpc: bpc, width: width, stride: (width*n*bpc + 7) / 8}
+}
+
+// Returns component i of the sample at x, y, or 0 past the end of data
+func (b *bits) at(x, y, i int) int {
+	bit := (x*b.n + i) * b.bpc
+	j := y*b.stride + bit/8
+	if j >= len(b.data) {
+		return 0
+	}
+	switch b.bpc {
+	case 8:
+		return int(b.data[j])
+	case 16:
+		if j+1 >= len(b.data) {
+			return 0
+		}
+		return int(b.data[j])<<8 | int(b.data[j+1])
+	}
+	shift := uint(8 - bit%8 - b.bpc)
+	return int(b.data[j]>>shift) & (1<<uint(b.bpc) - 1)
+}
+
+// Color space of an image with n components that maps to base
+// components: 1 gray, 3 RGB or 4 CMYK
+type colorSpace struct {
+	n, base int
+	invert  bool   // Separation tints, where 1 is full ink
+	lookup  []byte // Palette of base components for indexed images
+}
+
+// Returns the color of components in [0, 1], or an index
+func (cs *colorSpace) color(comp []float64) color.Color {
+	if cs.lookup != nil {
+		i := int(comp[0])
+		var c [4]float64
+		for k := 0; k < cs.base; k++ {
+			if j := i*cs.base + k; j >= 0 && j < len(cs.lookup) {
+				c[k] = float64(cs.lookup[j]) / 255
+			}
+		}
+		return baseColor(c[:cs.base])
+	}
+	return baseColor(comp)
+}
+
+func baseColor(c []float64) color.Color {
+	switch len(c) {
+	case 1:
+		return color.Gray{clamp(c[0])}
+	case 3:
+		return color.RGBA{clamp(c[0]), clamp(c[1]), clamp(c[2]), 255}
+	}
+	return color.CMYK{clamp(c[0]), clamp(c[1]), clamp(c[2]), clamp(c[3])}
+}
+
+// Scales [0, 1] to [0, 255]
+func clamp(v float64) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))
+}
+
+// Resolves an image color space
+func (r *Reader) colorSpace(o object) (*colorSpace, error) {
+	switch cs := r.resolve(o).(type) {
+	case name:
+		switch cs {
+		case "DeviceGray", "G", "CalGray":
+			return &colorSpace{n: 1, base: 1}, nil
+		case "DeviceRGB", "RGB", "CalRGB":
+			return &colorSpace{n: 3, base: 3}, nil
+		case "DeviceCMYK", "CMYK":
+			return &colorSpace{n: 4, base: 4}, nil
+		}
+		return nil, fmt.Errorf("Unsupported color space %s", cs)
+	case []object:
+		if len(cs) == 0 {
+			break
+		}
+		family, _ := r.resolve(cs[0]).(name)
+		switch family {
+		case "CalGray", "CalRGB", "DeviceGray", "DeviceRGB", "DeviceCMYK":
+			return r.colorSpace(family)
+		case "ICCBased":
+			if len(cs) < 2 {
+				break
+			}
+			s, ok := r.resolve(cs[1]).(*stream)
+			if !ok {
+				break
+			}
+			switch n, _ := r.resolve(s.hdr["N"]).(int); n {
+			case 1, 3, 4:
+				return &colorSpace{n: n, base: n}, nil
+			}
+		case "Indexed", "I":
+			if len(cs) < 4 {
+				break
+			}
+			base, err := r.colorSpace(cs[1])
+			if err != nil || base.lookup != nil {
+				return nil, fmt.Errorf("Unsupported indexed color space base %v", cs[1])
+			}
+			var lookup []byte
+			switch t := r.resolve(cs[3]).(type) {
+			case string:
+				lookup = []byte(t)
+			case *stream:
+				if lookup, err = r.decode(t); err != nil {
+					return nil, err
+				}
+			}
+			return &colorSpace{n: 1, base: base.base, lookup: lookup}, nil
+		case "Separation":
+			return &colorSpace{n: 1, base: 1, invert: true}, nil
+		}
+		return nil, fmt.Errorf("Unsupported color space %s", family)
+	}
+	return nil, fmt.Errorf("Unsupported color space %v", o)
+}
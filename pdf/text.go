@@ -0,0 +1,514 @@
+package pdf
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Returns the text shown on page n in content order, with a line break
+// wherever the baseline moves and a space wherever a gap is left
+func (r *Reader) PageText(n int) (string, error) {
+	page, err := r.page(n)
+	if err != nil {
+		return "", err
+	}
+	content, err := r.contents(page)
+	if err != nil {
+		return "", fmt.Errorf("Page %d: %v", n, err)
+	}
+	resources, _ := r.resolve(page["Resources"]).(dict)
+	t := &textWriter{r: r, fonts: make(map[ref]*font)}
+	if err := t.walk(content, resources, identity, 0); err != nil {
+		return "", fmt.Errorf("Page %d: %v", n, err)
+	}
+	if t.b.Len() > 0 {
+		t.b.WriteByte('\n')
+	}
+	return t.b.String(), nil
+}
+
+// Collects the text of content streams
+type textWriter struct {
+	r     *Reader
+	fonts map[ref]*font
+	b     strings.Builder
+	// End of the last text shown, in user space
+	have         bool
+	lastX, lastY float64
+	lastSize     float64
+}
+
+// Text state that persists across BT and ET but is saved by q and Q
+type textState struct {
+	ctm                    matrix
+	font                   *font
+	size, tc, tw, tz, lead float64
+}
+
+func (t *textWriter) walk(content []byte, resources dict, ctm matrix, depth int) error {
+	if depth > 16 {
+		return fmt.Errorf("Forms are nested too deeply")
+	}
+	st := textState{ctm: ctm, tz: 100}
+	var stack []textState
+	tm, tlm := identity, identity
+	num := func(args []object, i int) float64 {
+		if i < 0 || i >= len(args) {
+			return 0
+		}
+		v, _ := t.r.number(args[i])
+		return v
+	}
+	move := func(tx, ty float64) {
+		tlm = matrix{1, 0, 0, 1, tx, ty}.mul(tlm)
+		tm = tlm
+	}
+	show := func(s string) {
+		if st.font != nil {
+			tm = t.show(s, &st, tm)
+		}
+	}
+	return operators(content, func(op string, args []object) error {
+		switch op {
+		case "q":
+			stack = append(stack, st)
+		case "Q":
+			if len(stack) > 0 {
+				st = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case "cm":
+			if m, ok := t.r.matrixOf(args); ok {
+				st.ctm = m.mul(st.ctm)
+			}
+		case "BT":
+			tm, tlm = identity, identity
+		case "Tf":
+			if len(args) >= 2 {
+				key, _ := args[0].(name)
+				st.font = t.font(resources, key)
+				st.size = num(args, 1)
+			}
+		case "Tc":
+			st.tc = num(args, 0)
+		case "Tw":
+			st.tw = num(args, 0)
+		case "Tz":
+			st.tz = num(args, 0)
+		case "TL":
+			st.lead = num(args, 0)
+		case "Td":
+			move(num(args, 0), num(args, 1))
+		case "TD":
+			st.lead = -num(args, 1)
+			move(num(args, 0), num(args, 1))
+		case "Tm":
+			if m, ok := t.r.matrixOf(args); ok {
+				tm, tlm = m, m
+			}
+		case "T*":
+			move(0, -st.lead)
+		case "Tj":
+			if len(args) >= 1 {
+				s, _ := args[0].(string)
+				show(s)
+			}
+		case "'":
+			move(0, -st.lead)
+			if len(args) >= 1 {
+				s, _ := args[len(args)-1].(string)
+				show(s)
+			}
+		case "\"":
+			if len(args) >= 3 {
+				st.tw, st.tc = num(args, 0), num(args, 1)
+				move(0, -st.lead)
+				s, _ := args[2].(string)
+				show(s)
+			}
+		case "TJ":
+			if len(args) < 1 {
+				break
+			}
+			a, _ := args[0].([]object)
+			for _, o := range a {
+				if s, ok := o.(string); ok {
+					show(s)
+				} else if adjust, ok := t.r.number(o); ok {
+					tx := -adjust / 1000 * st.size * st.tz / 100
+					tm = matrix{1, 0, 0, 1, tx, 0}.mul(tm)
+				}
+			}
+		case "Do":
+			if len(args) < 1 {
+				break
+			}
+			key, _ := args[0].(name)
+			s, ok := t.r.resource(resources, "XObject", key).(*stream)
+			if !ok || s.hdr["Subtype"] != name("Form") {
+				break
+			}
+			m := t.r.formMatrix(s)
+			data, err := t.r.decode(s)
+			if err != nil {
+				return err
+			}
+			res, ok := t.r.resolve(s.hdr["Resources"]).(dict)
+			if !ok {
+				res = resources
+			}
+			return t.walk(data, res, m.mul(st.ctm), depth+1)
+		}
+		return nil
+	})
+}
+
+// Writes the string in the current font, separated from the previous text
+// by a space or line break if needed. Returns the advanced text matrix
+func (t *textWriter) show(s string, st *textState, tm matrix) matrix {
+	trm := tm.mul(st.ctm)
+	x, y := trm.apply(0, 0)
+	size := st.size * math.Hypot(trm[2], trm[3])
+	if size <= 0 {
+		size = 1
+	}
+	for _, g := range st.font.glyphs(s) {
+		if g.text != "" && !isBlank(g.text) {
+			if t.have {
+				height := math.Max(size, t.lastSize)
+				if math.Abs(y-t.lastY) > height/2 {
+					t.b.WriteByte('\n')
+				} else if math.Abs(x-t.lastX) > size/5 && !strings.HasSuffix(t.b.String(), " ") {
+					t.b.WriteByte(' ')
+				}
+			}
+			t.b.WriteString(g.text)
+			t.have = true
+		} else if g.text != "" && t.have && !strings.HasSuffix(t.b.String(), " ") {
+			t.b.WriteByte(' ')
+		}
+		tx := g.width/1000*st.size + st.tc
+		if g.space {
+			tx += st.tw
+		}
+		tm = matrix{1, 0, 0, 1, tx * st.tz / 100, 0}.mul(tm)
+		trm = tm.mul(st.ctm)
+		x, y = trm.apply(0, 0)
+		if t.have {
+			t.lastX, t.lastY, t.lastSize = x, y, size
+		}
+	}
+	return tm
+}
+
+func isBlank(s string) bool {
+	return strings.TrimSpace(s) == ""
+}
+
+// Font of a text string, mapping its codes to text and widths
+type font struct {
+	twoByte  bool              // Type0 fonts default to 2-byte codes
+	lengths  map[byte]int      // Code length by first byte, from the CMap
+	unicode  map[string]string // Text of each code, from the CMap
+	encoding [256]string       // Text of each code in simple fonts
+	widths   map[int]float64   // In thousandths of text space units
+	missing  float64           // Width of codes missing from widths
+}
+
+// A shown character code
+type glyph struct {
+	text  string
+	width float64
+	space bool // Single-byte code 32, which word spacing applies to
+}
+
+// Splits the string into codes
+func (f *font) glyphs(s string) []glyph {
+	var gs []glyph
+	for i := 0; i < len(s); {
+		n := 1
+		if f.twoByte {
+			n = 2
+		}
+		if l, ok := f.lengths[s[i]]; ok {
+			n = l
+		}
+		if i+n > len(s) {
+			n = len(s) - i
+		}
+		code := s[i : i+n]
+		i += n
+		c := 0
+		for j := 0; j < len(code); j++ {
+			c = c<<8 | int(code[j])
+		}
+		g := glyph{space: n == 1 && c == 32}
+		if text, ok := f.unicode[code]; ok {
+			g.text = text
+		} else if !f.twoByte && n == 1 {
+			g.text = f.encoding[c]
+		}
+		if w, ok := f.widths[c]; ok {
+			g.width = w
+		} else {
+			g.width = f.missing
+		}
+		gs = append(gs, g)
+	}
+	return gs
+}
+
+// Returns the named font, or nil if missing
+func (t *textWriter) font(resources dict, key name) *font {
+	o := t.r.dictOf(resources, "Font")[key]
+	x, isRef := o.(ref)
+	if f, ok := t.fonts[x]; isRef && ok {
+		return f
+	}
+	d, ok := t.r.resolve(o).(dict)
+	if !ok {
+		return nil
+	}
+	f := &font{widths: make(map[int]float64), missing: 500}
+	if d["Subtype"] == name("Type0") {
+		f.twoByte = true
+		f.missing = 1000
+		if a, ok := t.r.resolve(d["DescendantFonts"]).([]object); ok && len(a) > 0 {
+			if cid, ok := t.r.resolve(a[0]).(dict); ok {
+				t.r.cidWidths(cid, f)
+			}
+		}
+	} else {
+		f.encoding = t.r.simpleEncoding(d)
+		first, _ := t.r.resolve(d["FirstChar"]).(int)
+		for i, w := range t.r.numbers(d["Widths"]) {
+			f.widths[first+i] = w
+		}
+	}
+	if s, ok := t.r.resolve(d["ToUnicode"]).(*stream); ok {
+		if data, err := t.r.decode(s); err == nil {
+			f.lengths, f.unicode = parseCMap(data)
+		}
+	}
+	if isRef {
+		t.fonts[x] = f
+	}
+	return f
+}
+
+// Reads the /W and /DW widths of a CIDFont
+func (r *Reader) cidWidths(cid dict, f *font) {
+	if dw, ok := r.number(cid["DW"]); ok {
+		f.missing = dw
+	}
+	w, _ := r.resolve(cid["W"]).([]object)
+	for i := 0; i+1 < len(w); {
+		first, _ := r.resolve(w[i]).(int)
+		if a, ok := r.resolve(w[i+1]).([]object); ok {
+			// c [w1 w2 ...]
+			for j, o := range a {
+				f.widths[first+j], _ = r.number(o)
+			}
+			i += 2
+			continue
+		}
+		// cFirst cLast w
+		if i+2 >= len(w) {
+			break
+		}
+		last, _ := r.resolve(w[i+1]).(int)
+		width, _ := r.number(w[i+2])
+		for c := first; c <= last && c-first < 1<<16; c++ {
+			f.widths[c] = width
+		}
+		i += 3
+	}
+}
+
+// Parses the code lengths and text of a ToUnicode CMap
+func parseCMap(data []byte) (map[byte]int, map[string]string) {
+	lengths := make(map[byte]int)
+	unicode := make(map[string]string)
+	_ = operators(data, func(op string, args []object) error {
+		switch op {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(args); i += 2 {
+				lo, _ := args[i].(string)
+				hi, _ := args[i+1].(string)
+				if len(lo) == 0 || len(lo) != len(hi) {
+					continue
+				}
+				for c := int(lo[0]); c <= int(hi[0]); c++ {
+					lengths[byte(c)] = len(lo)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(args); i += 2 {
+				code, _ := args[i].(string)
+				dst, _ := args[i+1].(string)
+				unicode[code] = utf16BE(dst)
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(args); i += 3 {
+				lo, _ := args[i].(string)
+				hi, _ := args[i+1].(string)
+				if len(lo) == 0 || len(lo) != len(hi) {
+					continue
+				}
+				from, to := codeValue(lo), codeValue(hi)
+				for c := from; c <= to && c-from < 1<<16; c++ {
+					code := codeString(c, len(lo))
+					switch dst := args[i+2].(type) {
+					case string:
+						// Increment the last character of dst
+						if len(dst) == 0 {
+							continue
+						}
+						b := []byte(dst)
+						b[len(b)-1] += byte(c - from)
+						unicode[code] = utf16BE(string(b))
+					case []object:
+						if c-from < len(dst) {
+							if s, ok := dst[c-from].(string); ok {
+								unicode[code] = utf16BE(s)
+							}
+						}
+					}
+				}
+			}
+		}
+		return nil
+	})
+	return lengths, unicode
+}
+
+func codeValue(s string) int {
+	v := 0
+	for i := 0; i < len(s); i++ {
+		v = v<<8 | int(s[i])
+	}
+	return v
+}
+
+func codeString(v, n int) string {
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return string(b)
+}
+
+// Decodes UTF-16BE text
+func utf16BE(s string) string {
+	u := make([]uint16, len(s)/2)
+	for i := range u {
+		u[i] = uint16(s[2*i])<<8 | uint16(s[2*i+1])
+	}
+	return string(utf16.Decode(u))
+}
+
+// Returns the text of each code of a simple font: WinAnsiEncoding, which
+// covers StandardEncoding's letters and digits, with any /Differences
+func (r *Reader) simpleEncoding(d dict) [256]string {
+	var enc [256]string
+	for c := 32; c < 256; c++ {
+		if c >= 0x80 && c < 0xa0 {
+			if w := winAnsi[c-0x80]; w != 0 {
+				enc[c] = string(w)
+			}
+		} else if c != 0x7f && c != 0xad {
+			enc[c] = string(rune(c))
+		}
+	}
+	e, ok := r.resolve(d["Encoding"]).(dict)
+	if !ok {
+		return enc
+	}
+	code := 0
+	a, _ := r.resolve(e["Differences"]).([]object)
+	for _, o := range a {
+		switch v := o.(type) {
+		case int:
+			code = v
+		case name:
+			if code >= 0 && code < 256 {
+				if text, ok := glyphText(string(v)); ok {
+					enc[code] = text
+				}
+			}
+			code++
+		}
+	}
+	return enc
+}
+
+// WinAnsiEncoding of 0x80 through 0x9f, which differs from Latin-1
+var winAnsi = [32]rune{
+	'€', 0, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0, 'Ž', 0,
+	0, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0, 'ž', 'Ÿ',
+}
+
+// Text of common glyph names that are not a single letter
+var glyphNames = map[string]string{
+	"space": " ", "exclam": "!", "quotedbl": "\"", "numbersign": "#",
+	"dollar": "$", "percent": "%", "ampersand": "&", "quotesingle": "'",
+	"quoteright": "’", "parenleft": "(", "parenright": ")", "asterisk": "*",
+	"plus": "+", "comma": ",", "hyphen": "-", "period": ".", "slash": "/",
+	"zero": "0", "one": "1", "two": "2", "three": "3", "four": "4",
+	"five": "5", "six": "6", "seven": "7", "eight": "8", "nine": "9",
+	"colon": ":", "semicolon": ";", "less": "<", "equal": "=",
+	"greater": ">", "question": "?", "at": "@", "bracketleft": "[",
+	"backslash": "\\", "bracketright": "]", "asciicircum": "^",
+	"underscore": "_", "grave": "`", "quoteleft": "‘", "braceleft": "{",
+	"bar": "|", "braceright": "}", "asciitilde": "~", "endash": "–",
+	"emdash": "—", "quotedblleft": "“", "quotedblright": "”",
+	"quotesinglbase": "‚", "quotedblbase": "„", "bullet": "•",
+	"ellipsis": "…", "dagger": "†", "daggerdbl": "‡", "section": "§",
+	"paragraph": "¶", "copyright": "©", "registered": "®",
+	"trademark": "™", "degree": "°", "minus": "−", "fi": "fi", "fl": "fl",
+	"ff": "ff", "ffi": "ffi", "ffl": "ffl", "germandbls": "ß", "ae": "æ",
+	"AE": "Æ", "oe": "œ", "OE": "Œ", "oslash": "ø", "Oslash": "Ø",
+	"dotlessi": "ı", "exclamdown": "¡", "questiondown": "¿",
+	"guillemotleft": "«", "guillemotright": "»", "cent": "¢",
+	"sterling": "£", "yen": "¥", "Euro": "€", "nbspace": " ",
+}
+
+// Combining marks of glyph names such as eacute
+var accents = map[string]string{
+	"grave": "\u0300", "acute": "\u0301", "circumflex": "\u0302",
+	"tilde": "\u0303", "dieresis": "\u0308", "ring": "\u030a",
+	"cedilla": "\u0327", "caron": "\u030c",
+}
+
+// Returns the text of a glyph name such as a, eacute, uni00E9 or u1F600
+func glyphText(g string) (string, bool) {
+	if i := strings.IndexByte(g, '.'); i > 0 {
+		g = g[:i] // Variants such as a.sc
+	}
+	if text, ok := glyphNames[g]; ok {
+		return text, true
+	}
+	if len(g) == 1 {
+		return g, true
+	}
+	if strings.HasPrefix(g, "uni") && len(g) == 7 {
+		if v, err := strconv.ParseUint(g[3:], 16, 16); err == nil {
+			return string(rune(v)), true
+		}
+	}
+	if strings.HasPrefix(g, "u") && len(g) >= 5 && len(g) <= 7 {
+		if v, err := strconv.ParseUint(g[1:], 16, 32); err == nil {
+			return string(rune(v)), true
+		}
+	}
+	if mark, ok := accents[g[1:]]; ok {
+		return norm.NFC.String(g[:1] + mark), true
+	}
+	return "", false
+}